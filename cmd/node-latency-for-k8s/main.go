@@ -25,16 +25,23 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/samber/lo"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -46,22 +53,58 @@ import (
 var (
 	version string
 	commit  string
+	// dimensionNameRegex matches valid Prometheus label names which are also valid CloudWatch dimension names
+	dimensionNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 )
 
 type Options struct {
 	CloudWatch          bool
+	CloudWatchNamespace string
+	CloudWatchDims      string
+	CloudWatchHighRes   bool
+	CloudWatchEMF       bool
+	CloudWatchEMFFile   string
 	Prometheus          bool
+	NodeAnnotations     bool
+	NodeBucketLabel     bool
+	NodeBuckets         string
+	NodeEventThresholds string
+	NodeSummaryEvent    bool
+	WebhookURL          string
+	WebhookHeaders      string
+	WebhookRetries      int
+	WebhookHMACSecret   string
+	ClusterName         string
+	S3Bucket            string
+	S3Endpoint          string
+	S3PathStyle         bool
+	S3Gzip              bool
+	S3ExcerptLines      int
 	ExperimentDimension string
 	TimeoutSeconds      int
 	RetryDelaySeconds   int
+	SourceTimeout       int
+	SourceTimeouts      string
+	IMDSDimensions      string
+	LabelDimensions     string
+	AnnotDimensions     string
+	Dimensions          keyValueFlag
+	MetricDimensions    string
+	OSReleasePath       string
+	Profile             string
+	CNIConfDir          string
+	CNIProfiles         string
 	MetricsPort         int
 	IMDSEndpoint        string
 	Kubeconfig          string
 	PodNamespace        string
 	NodeName            string
 	NoIMDS              bool
+	CurrentBootOnly     bool
+	Boot                string
 	Output              string
 	NoComments          bool
+	Explain             bool
 	Version             bool
 }
 
@@ -78,6 +121,8 @@ func main() {
 	ctx := context.Background()
 	var err error
 	latencyClient := latency.New()
+	latency.OSReleasePath = options.OSReleasePath
+	latency.CNIConfDir = options.CNIConfDir
 
 	// Setup K8s clientset
 	var k8sConfig *rest.Config
@@ -94,7 +139,11 @@ func main() {
 		if err != nil {
 			log.Fatalf("Unable to create K8s clientset: %s", err)
 		}
-		latencyClient = latencyClient.WithK8sClientset(clientset).WithPodNamespace(options.PodNamespace).WithNodeName(options.NodeName)
+		dynamicClient, err := dynamic.NewForConfig(k8sConfig)
+		if err != nil {
+			log.Fatalf("Unable to create K8s dynamic client: %s", err)
+		}
+		latencyClient = latencyClient.WithK8sClientset(clientset).WithK8sDynamicClient(dynamicClient).WithPodNamespace(options.PodNamespace).WithNodeName(options.NodeName)
 	} else {
 		log.Printf("Unable to find in-cluster K8s config: %s\n", err)
 	}
@@ -107,7 +156,79 @@ func main() {
 	if !options.NoIMDS {
 		latencyClient = latencyClient.WithIMDS(imds.NewFromConfig(cfg))
 	}
-	latencyClient = latencyClient.WithEC2Client(ec2.NewFromConfig(cfg))
+	latencyClient = latencyClient.WithEC2Client(ec2.NewFromConfig(cfg)).WithAutoScalingClient(autoscaling.NewFromConfig(cfg)).WithCurrentBootOnly(options.CurrentBootOnly)
+	// boot scoping is opt-in so that replaying captured logs on another host measures them as before
+	var bootSelector latency.BootSelector
+	if options.Boot != "" {
+		bootSelector, err = latency.ParseBootSelector(options.Boot)
+		if err != nil {
+			log.Fatalf("unable to parse boot selector: %s", err)
+		}
+		latencyClient = latencyClient.WithBootSelector(bootSelector)
+	}
+	sourceTimeouts, err := parseThresholds(options.SourceTimeouts)
+	if err != nil {
+		log.Fatalf("unable to parse source timeouts: %s", err)
+	}
+	imdsDimensions, err := parseDimensions(options.IMDSDimensions)
+	if err != nil {
+		log.Fatalf("unable to parse IMDS dimensions: %s", err)
+	}
+	labelDimensions, err := parseDimensions(options.LabelDimensions)
+	if err != nil {
+		log.Fatalf("unable to parse node label dimensions: %s", err)
+	}
+	annotationDimensions, err := parseDimensions(options.AnnotDimensions)
+	if err != nil {
+		log.Fatalf("unable to parse node annotation dimensions: %s", err)
+	}
+	if err := validateDimensionNames(options.Dimensions); err != nil {
+		log.Fatalf("unable to parse dimensions: %s", err)
+	}
+	latencyClient = latencyClient.WithIMDSDimensions(imdsDimensions).WithNodeLabelDimensions(labelDimensions).
+		WithNodeAnnotationDimensions(annotationDimensions).WithDimensions(options.Dimensions)
+	metricDimensions := latency.DefaultMetricDimensions
+	if options.MetricDimensions != "" {
+		metricDimensions, err = parseMetricDimensions(options.MetricDimensions)
+		if err != nil {
+			log.Fatalf("unable to parse metric dimensions: %s", err)
+		}
+		latencyClient = latencyClient.WithMetricDimensions(metricDimensions)
+	}
+	cwDimensions, err := parseKeyValues(options.CloudWatchDims)
+	if err == nil {
+		err = validateReservedDimensions(cwDimensions)
+	}
+	if err != nil {
+		log.Fatalf("unable to parse CloudWatch dimensions: %s", err)
+	}
+	// fail before measuring rather than having CloudWatch reject the metrics, the cloud-init stage and module durations also have a stage or module dimension
+	if options.CloudWatch || options.CloudWatchEMF {
+		dimensions := lo.Uniq(slices.Concat([]string{"experiment", "stage|module"}, metricDimensions, lo.Keys(imdsDimensions), lo.Keys(labelDimensions),
+			lo.Keys(annotationDimensions), lo.Keys(options.Dimensions), lo.Keys(cwDimensions)))
+		if len(dimensions) > latency.MaxCloudWatchDimensions {
+			log.Fatalf("metrics would have %d dimensions but CloudWatch allows at most %d: %s", len(dimensions), latency.MaxCloudWatchDimensions, strings.Join(dimensions, ","))
+		}
+	}
+	latencyClient = latencyClient.WithSourceTimeout(time.Duration(options.SourceTimeout) * time.Second).WithSourceTimeouts(sourceTimeouts)
+	if options.Profile != "" {
+		profile, err := latency.GetProfile(options.Profile)
+		if err != nil {
+			log.Fatalf("unable to select profile: %s", err)
+		}
+		latencyClient = latencyClient.WithProfile(profile)
+	}
+	if options.CNIProfiles != "" {
+		var cniProfiles []*latency.CNIProfile
+		for _, name := range strings.Split(options.CNIProfiles, ",") {
+			cniProfile, err := latency.GetCNIProfile(strings.TrimSpace(name))
+			if err != nil {
+				log.Fatalf("unable to select CNI profile: %s", err)
+			}
+			cniProfiles = append(cniProfiles, cniProfile)
+		}
+		latencyClient = latencyClient.WithCNIProfiles(cniProfiles...)
+	}
 
 	// Register the Default Sources and Events
 	latencyClient, err = latencyClient.RegisterDefaultSources().RegisterDefaultEvents()
@@ -116,21 +237,61 @@ func main() {
 		log.Printf("    %s", err)
 	}
 
+	// Explain why events could not be measured from a single measurement if flag is enabled
+	if options.Explain {
+		explanation := latencyClient.Explain(ctx, latencyClient.Measure(ctx))
+		if options.Output == "json" {
+			jsonExplanation, err := json.MarshalIndent(explanation, "", "    ")
+			if err != nil {
+				log.Fatalf("unable to marshal json explanation: %v", err)
+			}
+			fmt.Println(string(jsonExplanation))
+		} else {
+			explanation.Write(os.Stdout)
+		}
+		os.Exit(0)
+	}
+
 	// Take measurements
-	measurement, err := latencyClient.MeasureUntil(ctx, time.Duration(options.TimeoutSeconds)*time.Second, time.Duration(options.RetryDelaySeconds)*time.Second)
+	timeout := time.Duration(options.TimeoutSeconds) * time.Second
+	retryDelay := time.Duration(options.RetryDelaySeconds) * time.Second
+	var measurements []*latency.Measurement
+	if bootSelector.All {
+		measurements, err = latencyClient.MeasureBoots(ctx, timeout, retryDelay)
+	} else {
+		var measurement *latency.Measurement
+		measurement, err = latencyClient.MeasureUntil(ctx, timeout, retryDelay)
+		measurements = append(measurements, measurement)
+	}
 	if err != nil {
 		log.Println(err)
 	}
+	// the current boot's Measurement is used for metrics and all other sinks
+	measurement := lo.FindOrElse(measurements, measurements[len(measurements)-1], func(m *latency.Measurement) bool {
+		return m.Boot != nil && m.Boot.Current
+	})
 
 	// Emit Measurement to stdout based on output type
 	switch options.Output {
 	case "json":
-		jsonMeasurement, err := json.MarshalIndent(measurement, "", "    ")
+		var jsonMeasurement []byte
+		if bootSelector.All {
+			jsonMeasurement, err = json.MarshalIndent(measurements, "", "    ")
+		} else {
+			jsonMeasurement, err = json.MarshalIndent(measurement, "", "    ")
+		}
 		if err != nil {
 			log.Printf("unable to marshal json output: %v", err)
 		} else {
 			fmt.Println(string(jsonMeasurement))
 		}
+	case "chrome-trace":
+		traceMeasurement, err := measurement.MarshalChromeTrace()
+		if err != nil {
+			log.Printf("unable to marshal chrome-trace output: %v", err)
+		} else {
+			fmt.Println(string(traceMeasurement))
+		}
 	default:
 		fallthrough
 	case "markdown":
@@ -138,23 +299,107 @@ func main() {
 		if options.NoComments {
 			hiddenColumns = append(hiddenColumns, latency.ChartColumnComment)
 		}
-		measurement.Chart(latency.ChartOptions{HiddenColumns: hiddenColumns})
+		for i, m := range measurements {
+			if i != 0 {
+				fmt.Println()
+			}
+			m.Chart(latency.ChartOptions{HiddenColumns: hiddenColumns, ShowBoot: bootSelector.All})
+		}
+	}
+
+	cwOptions := latency.CloudWatchOptions{
+		Namespace:           options.CloudWatchNamespace,
+		ExperimentDimension: options.ExperimentDimension,
+		Dimensions:          cwDimensions,
+		HighResolution:      options.CloudWatchHighRes,
 	}
 
 	// Emit CloudWatch Metrics if flag is enabled
 	if options.CloudWatch {
-		cfg, err := config.LoadDefaultConfig(ctx)
-		if err != nil {
-			log.Fatalf("unable to load AWS SDK config, %s", err)
-		}
 		cw := cloudwatch.NewFromConfig(cfg)
-		if err := measurement.EmitCloudWatchMetrics(ctx, cw, options.ExperimentDimension); err != nil {
+		if err := measurement.EmitCloudWatchMetrics(ctx, cw, cwOptions); err != nil {
 			log.Printf("Error emitting CloudWatch metrics: %s\n", err)
 		} else {
 			log.Println("Successfully emitted CloudWatch metrics")
 		}
 	}
 
+	// Write CloudWatch Embedded Metric Format (EMF) log lines if flag is enabled
+	if options.CloudWatchEMF {
+		if err := writeEMF(measurement, options.CloudWatchEMFFile, cwOptions); err != nil {
+			log.Printf("Error writing CloudWatch EMF logs: %s\n", err)
+		}
+	}
+
+	// Annotate the Node with timings if flag is enabled
+	if options.NodeAnnotations {
+		buckets, err := parseDurations(options.NodeBuckets)
+		if err != nil {
+			log.Fatalf("unable to parse node ready buckets: %s", err)
+		}
+		if err := latencyClient.AnnotateNode(ctx, measurement, latency.NodeAnnotationOptions{
+			BucketLabel: options.NodeBucketLabel,
+			Buckets:     buckets,
+		}); err != nil {
+			log.Printf("Error annotating node: %s\n", err)
+		} else {
+			log.Println("Successfully annotated node")
+		}
+	}
+
+	// Record K8s Events against the Node if thresholds or the summary event are configured
+	if options.NodeEventThresholds != "" || options.NodeSummaryEvent {
+		thresholds, err := parseThresholds(options.NodeEventThresholds)
+		if err != nil {
+			log.Fatalf("unable to parse node event thresholds: %s", err)
+		}
+		if err := latencyClient.RecordNodeEvents(ctx, measurement, latency.NodeEventOptions{
+			Thresholds: thresholds,
+			Summary:    options.NodeSummaryEvent,
+		}); err != nil {
+			log.Printf("Error recording node events: %s\n", err)
+		}
+	}
+
+	// POST the Measurement to a webhook if a URL is configured
+	if options.WebhookURL != "" {
+		headers, err := parseKeyValues(options.WebhookHeaders)
+		if err != nil {
+			log.Fatalf("unable to parse webhook headers: %s", err)
+		}
+		if err := measurement.PostWebhook(ctx, latency.WebhookOptions{
+			URL:        options.WebhookURL,
+			Headers:    headers,
+			Retries:    options.WebhookRetries,
+			HMACSecret: options.WebhookHMACSecret,
+		}); err != nil {
+			log.Printf("Error posting measurement to webhook: %s\n", err)
+		} else {
+			log.Println("Successfully posted measurement to webhook")
+		}
+	}
+
+	// Archive the Measurement and matched lines to S3 if a bucket is configured
+	if options.S3Bucket != "" {
+		s3Client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+			if options.S3Endpoint != "" {
+				o.BaseEndpoint = aws.String(options.S3Endpoint)
+			}
+			o.UsePathStyle = options.S3PathStyle
+		})
+		location, err := measurement.ArchiveS3(ctx, s3Client, latency.S3Options{
+			Bucket:       options.S3Bucket,
+			Cluster:      options.ClusterName,
+			Gzip:         options.S3Gzip,
+			ExcerptLines: options.S3ExcerptLines,
+		})
+		if err != nil {
+			log.Printf("Error archiving measurement to S3: %s\n", err)
+		} else {
+			log.Printf("Successfully archived measurement to %s\n", location)
+		}
+	}
+
 	// Serve Prometheus Metrics if flag is enabled
 	if options.Prometheus {
 		registry := prometheus.NewRegistry()
@@ -178,17 +423,55 @@ func main() {
 func MustParseFlags(f *flag.FlagSet) Options {
 	options := Options{}
 	f.BoolVar(&options.CloudWatch, "cloudwatch-metrics", boolEnv("CLOUDWATCH_METRICS", false), "Emit metrics to CloudWatch, default: false")
+	f.StringVar(&options.CloudWatchNamespace, "cloudwatch-namespace", strEnv("CLOUDWATCH_NAMESPACE", latency.DefaultCloudWatchNamespace), "CloudWatch namespace to emit metrics to, default: KubernetesNodeLatency")
+	f.StringVar(&options.CloudWatchDims, "cloudwatch-dimensions", strEnv("CLOUDWATCH_DIMENSIONS", ""), "Extra comma separated key=value dimensions to add to CloudWatch metrics, default: none")
+	f.BoolVar(&options.CloudWatchHighRes, "cloudwatch-high-resolution", boolEnv("CLOUDWATCH_HIGH_RESOLUTION", false), "Store CloudWatch metrics with 1 second resolution, default: false")
+	f.BoolVar(&options.CloudWatchEMF, "cloudwatch-emf", boolEnv("CLOUDWATCH_EMF", false), "Write metrics as CloudWatch Embedded Metric Format (EMF) log lines which does not require CloudWatch API permissions, default: false")
+	f.StringVar(&options.CloudWatchEMFFile, "cloudwatch-emf-file", strEnv("CLOUDWATCH_EMF_FILE", ""), "File to append CloudWatch EMF log lines to, default: stdout")
 	f.BoolVar(&options.Prometheus, "prometheus-metrics", boolEnv("PROMETHEUS_METRICS", false), "Expose a Prometheus metrics endpoint (this runs as a daemon), default: false")
 	f.IntVar(&options.MetricsPort, "metrics-port", intEnv("METRICS_PORT", 2112), "The port to serve prometheus metrics from, default: 2112")
 	f.StringVar(&options.ExperimentDimension, "experiment-dimension", strEnv("EXPERIMENT_DIMENSION", "none"), "Custom dimension to add to experiment metrics, default: none")
 	f.IntVar(&options.TimeoutSeconds, "timeout", intEnv("TIMEOUT", 600), "Timeout in seconds for how long event timings will try to be retrieved, default: 600")
 	f.IntVar(&options.RetryDelaySeconds, "retry-delay", intEnv("RETRY_DELAY", 5), "Delay in seconds in-between timing retrievals, default: 5")
+	f.IntVar(&options.SourceTimeout, "source-timeout", intEnv("SOURCE_TIMEOUT", 30), "Timeout in seconds for each source to find its events in a single timing retrieval, 0 disables the timeout, default: 30")
+	f.StringVar(&options.SourceTimeouts, "source-timeouts", strEnv("SOURCE_TIMEOUTS", ""), "Comma separated source=duration overrides of the source timeout (i.e. EC2=10s,K8s=5s), default: none")
+	f.BoolVar(&options.NodeAnnotations, "node-annotations", boolEnv("NODE_ANNOTATIONS", false), "Patch the node with timing annotations (requires node patch permissions), default: false")
+	f.BoolVar(&options.NodeBucketLabel, "node-ready-bucket-label", boolEnv("NODE_READY_BUCKET_LABEL", false), "Label the node with a node ready timing bucket (i.e. node-latency.k8s.aws/ready-bucket=lt60s) when --node-annotations is enabled, default: false")
+	f.StringVar(&options.NodeBuckets, "node-ready-buckets", strEnv("NODE_READY_BUCKETS", "30s,60s,90s,120s,180s,300s"), "Comma separated upper bounds of the node ready bucket label, default: 30s,60s,90s,120s,180s,300s")
+	f.StringVar(&options.NodeEventThresholds, "node-event-thresholds", strEnv("NODE_EVENT_THRESHOLDS", ""), "Comma separated metric=duration latency budgets (i.e. node_ready=90s), a Warning event is recorded against the node if any are exceeded, default: none")
+	f.BoolVar(&options.NodeSummaryEvent, "node-summary-event", boolEnv("NODE_SUMMARY_EVENT", false), "Record a Normal event against the node summarizing the measurement, default: false")
+	f.StringVar(&options.WebhookURL, "webhook-url", strEnv("WEBHOOK_URL", ""), "URL to POST the measurement JSON to when a run completes, default: none")
+	f.StringVar(&options.WebhookHeaders, "webhook-headers", strEnv("WEBHOOK_HEADERS", ""), "Comma separated key=value headers to add to webhook requests, default: none")
+	f.IntVar(&options.WebhookRetries, "webhook-retries", intEnv("WEBHOOK_RETRIES", latency.DefaultWebhookRetries), fmt.Sprintf("Number of webhook retries with exponential backoff, 0 disables retries, default: %d", latency.DefaultWebhookRetries))
+	f.StringVar(&options.WebhookHMACSecret, "webhook-hmac-secret", strEnv("WEBHOOK_HMAC_SECRET", ""), "Secret to sign webhook requests with an HMAC-SHA256 X-NLK-Signature-256 header, default: none")
+	f.StringVar(&options.ClusterName, "cluster-name", strEnv("CLUSTER_NAME", "default"), "Cluster name used as the S3 archive key prefix, default: default")
+	f.StringVar(&options.S3Bucket, "s3-bucket", strEnv("S3_BUCKET", ""), "S3 bucket to archive the measurement and matched log lines to under <cluster>/<date>/<instance-id>.json.gz (.json with --s3-gzip=false), default: none")
+	f.StringVar(&options.S3Endpoint, "s3-endpoint", strEnv("S3_ENDPOINT", ""), "S3 compatible endpoint (i.e. MinIO or localstack), default: <AWS S3>")
+	f.BoolVar(&options.S3PathStyle, "s3-path-style", boolEnv("S3_PATH_STYLE", false), "Use path style S3 addressing which is usually required by S3 compatible endpoints, default: false")
+	f.BoolVar(&options.S3Gzip, "s3-gzip", boolEnv("S3_GZIP", true), "Gzip compress the S3 archive and append .gz to its key, default: true")
+	f.IntVar(&options.S3ExcerptLines, "s3-excerpt-lines", intEnv("S3_EXCERPT_LINES", 0), "Number of log lines before and after each match to include in the S3 archive, default: 0")
 	f.StringVar(&options.IMDSEndpoint, "imds-endpoint", strEnv("IMDS_ENDPOINT", "http://169.254.169.254"), "IMDS endpoint for testing, default: http://169.254.169.254")
+	f.StringVar(&options.IMDSDimensions, "imds-dimensions", strEnv("IMDS_DIMENSIONS", ""), "Comma separated dimension=path EC2 IMDS metadata paths to add as metric dimensions (i.e. lifecycle=instance-life-cycle,placementGroup=placement/group-name), default: none")
+	f.StringVar(&options.MetricDimensions, "metric-dimensions", strEnv("METRIC_DIMENSIONS", ""), fmt.Sprintf("Comma separated metadata dimensions to add to CloudWatch and Prometheus metrics from %s, default: %s", strings.Join(slices.Concat(latency.DefaultMetricDimensions, latency.OptionalMetricDimensions), ","), strings.Join(latency.DefaultMetricDimensions, ",")))
+	f.StringVar(&options.LabelDimensions, "node-label-dimensions", strEnv("NODE_LABEL_DIMENSIONS", ""), "Comma separated dimension=label K8s Node labels to add as metric dimensions (i.e. team=example.com/team), default: none")
+	f.StringVar(&options.AnnotDimensions, "node-annotation-dimensions", strEnv("NODE_ANNOTATION_DIMENSIONS", ""), "Comma separated dimension=annotation K8s Node annotations to add as metric dimensions (i.e. testRunID=example.com/test-run-id), default: none")
+	options.Dimensions = keyValueFlag{}
+	if err := options.Dimensions.Set(strEnv("DIMENSIONS", "")); err != nil {
+		panic("Env Var DIMENSIONS must be comma separated key=value pairs")
+	}
+	f.Var(options.Dimensions, "dimension", "key=value dimension to add to metrics and the measurement metadata which can be repeated (i.e. --dimension team=platform --dimension testRunID=42), the DIMENSIONS env var accepts comma separated key=value pairs, default: none")
+	f.StringVar(&options.OSReleasePath, "os-release-path", strEnv("OS_RELEASE_PATH", latency.OSReleasePath), "Path to the host's os-release file which is read for metadata when running in a container, default: /etc/os-release")
+	f.StringVar(&options.Profile, "profile", strEnv("PROFILE", ""), fmt.Sprintf("Node OS profile which selects the log sources and events (%s), default: <detected from the os-release file>", strings.Join(lo.Map(latency.Profiles, func(p *latency.Profile, _ int) string { return p.Name }), ", ")))
+	f.StringVar(&options.CNIConfDir, "cni-conf-dir", strEnv("CNI_CONF_DIR", latency.CNIConfDir), "Path to the host's CNI config directory which is read to detect the CNI when running in a container, default: /etc/cni/net.d")
+	f.StringVar(&options.CNIProfiles, "cni", strEnv("CNI", ""), fmt.Sprintf("Comma separated CNI profiles which select the CNI log sources and events (%s), default: <detected from the CNI config directory>", strings.Join(lo.Map(latency.CNIProfiles, func(p *latency.CNIProfile, _ int) string { return p.Name }), ", ")))
 	f.BoolVar(&options.NoIMDS, "no-imds", boolEnv("NO_IMDS", false), "Do not use EC2 Instance Metadata Service (IMDS), default: false")
+	f.BoolVar(&options.CurrentBootOnly, "current-boot-only", boolEnv("CURRENT_BOOT_ONLY", false), "Only read log files (including rotated logs) that have been modified since the current boot, default: false")
+	f.StringVar(&options.Boot, "boot", strEnv("BOOT", ""), "Boot to measure when the logs contain multiple boots: current, previous, all (a measurement per boot), or a journalctl style index where 1 is the oldest boot and 0, -1, ... are relative to the current boot, default: <not scoped, the logs of every boot are searched>")
 	f.StringVar(&options.PodNamespace, "pod-namespace", strEnv("POD_NAMESPACE", "default"), "namespace of the pods that will be measured from creation to running, default: default")
 	f.StringVar(&options.NodeName, "node-name", strEnv("NODE_NAME", ""), "node name to query for the first pod creation time in the pod namespace, default: <auto-discovered via IMDS>")
-	f.StringVar(&options.Output, "output", strEnv("OUTPUT", "markdown"), "output type (markdown or json), default: markdown")
+	f.StringVar(&options.Output, "output", strEnv("OUTPUT", "markdown"), "output type (markdown, json, or chrome-trace), default: markdown")
 	f.BoolVar(&options.NoComments, "no-comments", boolEnv("NO_COMMENTS", false), "Hide the comments column in the markdown chart output, default: false")
+	f.BoolVar(&options.Explain, "explain", boolEnv("EXPLAIN", false), "Take a single measurement and explain why events could not be measured (resolved files, nearest matches, timestamp parse failures, and missing prerequisites) instead of emitting timings, default: false")
 	f.BoolVar(&options.Version, "version", false, "version information")
 	f.StringVar(&options.Kubeconfig, "kubeconfig", defaultKubeconfig(), "(optional) absolute path to the kubeconfig file")
 	lo.Must0(f.Parse(os.Args[1:]))
@@ -258,6 +541,135 @@ func boolEnv(key string, fallback bool) bool {
 	return envBoolValue
 }
 
+// parseKeyValues parses a comma separated list of key=value pairs into a map
+func parseKeyValues(keyValues string) (map[string]string, error) {
+	result := map[string]string{}
+	for _, kv := range strings.Split(keyValues, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid key=value pair \"%s\"", kv)
+		}
+		result[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return result, nil
+}
+
+// parseMetricDimensions parses a comma separated list of metric dimensions which must be default or optional metadata dimensions
+func parseMetricDimensions(dimensions string) ([]string, error) {
+	var result []string
+	for _, d := range strings.Split(dimensions, ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		if !lo.Contains(latency.DefaultMetricDimensions, d) && !lo.Contains(latency.OptionalMetricDimensions, d) {
+			return nil, fmt.Errorf("unknown metric dimension \"%s\"", d)
+		}
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+// keyValueFlag is a flag of key=value pairs which can be repeated or comma separated
+type keyValueFlag map[string]string
+
+// String returns the comma separated key=value pairs sorted by key
+func (kv keyValueFlag) String() string {
+	keys := lo.Keys(kv)
+	slices.Sort(keys)
+	return strings.Join(lo.Map(keys, func(k string, _ int) string { return k + "=" + kv[k] }), ",")
+}
+
+// Set adds comma separated key=value pairs to the flag
+func (kv keyValueFlag) Set(value string) error {
+	keyValues, err := parseKeyValues(value)
+	if err != nil {
+		return err
+	}
+	for k, v := range keyValues {
+		kv[k] = v
+	}
+	return nil
+}
+
+// parseDimensions parses comma separated dimension=key pairs and validates the dimension names
+func parseDimensions(dimensions string) (map[string]string, error) {
+	keyValues, err := parseKeyValues(dimensions)
+	if err != nil {
+		return nil, err
+	}
+	return keyValues, validateDimensionNames(keyValues)
+}
+
+// validateDimensionNames ensures dimension names are valid Prometheus label names and are not reserved
+func validateDimensionNames(dimensions map[string]string) error {
+	for dimension := range dimensions {
+		if !dimensionNameRegex.MatchString(dimension) {
+			return fmt.Errorf("invalid dimension name \"%s\", dimension names must match %s", dimension, dimensionNameRegex)
+		}
+	}
+	return validateReservedDimensions(dimensions)
+}
+
+// validateReservedDimensions ensures extra dimensions do not silently override the experiment or metadata dimensions
+func validateReservedDimensions(dimensions map[string]string) error {
+	for dimension := range dimensions {
+		if lo.Contains(latency.ReservedDimensions, dimension) {
+			return fmt.Errorf("dimension name \"%s\" is reserved, reserved dimension names are %s", dimension, strings.Join(latency.ReservedDimensions, ","))
+		}
+	}
+	return nil
+}
+
+// parseDurations parses a comma separated list of durations
+func parseDurations(durations string) ([]time.Duration, error) {
+	var result []time.Duration
+	for _, d := range strings.Split(durations, ",") {
+		if strings.TrimSpace(d) == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, duration)
+	}
+	return result, nil
+}
+
+// parseThresholds parses a comma separated list of metric=duration pairs
+func parseThresholds(thresholds string) (map[string]time.Duration, error) {
+	keyValues, err := parseKeyValues(thresholds)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]time.Duration{}
+	for metric, threshold := range keyValues {
+		duration, err := time.ParseDuration(threshold)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold for %s: %w", metric, err)
+		}
+		result[metric] = duration
+	}
+	return result, nil
+}
+
+// writeEMF writes CloudWatch EMF log lines for a measurement to a file or stdout if no file is specified
+func writeEMF(measurement *latency.Measurement, file string, opts latency.CloudWatchOptions) error {
+	if file == "" {
+		return measurement.WriteEMF(os.Stdout, opts)
+	}
+	emfFile, err := os.OpenFile(filepath.Clean(file), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open EMF file %s: %w", file, err)
+	}
+	defer emfFile.Close()
+	return measurement.WriteEMF(emfFile, opts)
+}
+
 func withIMDSEndpoint(imdsEndpoint string) func(*config.LoadOptions) error {
 	return func(lo *config.LoadOptions) error {
 		lo.EC2IMDSEndpoint = imdsEndpoint
@@ -0,0 +1,110 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"regexp"
+
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources/journal"
+)
+
+var (
+	// NodeadmName is the name of the journal source restricted to the nodeadm units
+	NodeadmName = "Nodeadm"
+	// nodeadmUnits write the containerd and kubelet config from the NodeConfig and then start the daemons
+	nodeadmUnits = []string{"nodeadm-config.service", "nodeadm-run.service"}
+
+	// nodeadm logs JSON lines (i.e. {"level":"info","ts":1700000000.1,"caller":"init/init.go:77","msg":"Loaded configuration",...})
+	nodeadmConfigStart       = regexp.MustCompile(`.*systemd\[[0-9]+\]: Starting (nodeadm-config\.service|EKS Nodeadm Config).*`)
+	nodeadmConfigFinish      = regexp.MustCompile(`.*systemd\[[0-9]+\]: Finished (nodeadm-config\.service|EKS Nodeadm Config).*`)
+	nodeadmConfigLoaded      = regexp.MustCompile(`.*nodeadm\[[0-9]+\]: .*"msg":"Loaded configuration".*`)
+	nodeadmContainerdConfig  = regexp.MustCompile(`.*nodeadm\[[0-9]+\]: .*"msg":"Writing containerd config to file.*`)
+	nodeadmKubeletConfig     = regexp.MustCompile(`.*nodeadm\[[0-9]+\]: .*"msg":"Writing kubelet config to file.*`)
+	nodeadmRunStart          = regexp.MustCompile(`.*systemd\[[0-9]+\]: Starting (nodeadm-run\.service|EKS Nodeadm Run).*`)
+	nodeadmRunFinish         = regexp.MustCompile(`.*systemd\[[0-9]+\]: Finished (nodeadm-run\.service|EKS Nodeadm Run).*`)
+	nodeadmContainerdRunning = regexp.MustCompile(`.*nodeadm\[[0-9]+\]: .*"msg":"Ensuring daemon is running[^"]*".*"name":"containerd".*`)
+	nodeadmKubeletRunning    = regexp.MustCompile(`.*nodeadm\[[0-9]+\]: .*"msg":"Ensuring daemon is running[^"]*".*"name":"kubelet".*`)
+
+	// ProfileAL2023 is the Amazon Linux 2023 profile which reads the journal and the nodeadm bootstrap events
+	ProfileAL2023 = &Profile{
+		Name: "al2023",
+		Detect: func(osRelease map[string]string) bool {
+			return osRelease["ID"] == "amzn" && osRelease["VERSION_ID"] == "2023"
+		},
+		SystemLog: journal.Name,
+		Sources: func(m *Measurer) []sources.Source {
+			journalPath := journal.DefaultPath()
+			// the unit sources share the journal's reader so that the journal files are only parsed once
+			journalReader := journal.NewReader()
+			return append([]sources.Source{
+				journal.New(journalPath).WithReader(journalReader).WithCurrentBootOnly(m.currentBootOnly),
+				journal.NewForUnits(NodeadmName, journalPath, nodeadmUnits...).WithReader(journalReader).WithCurrentBootOnly(m.currentBootOnly),
+			}, cloudInitSources(m)...)
+		},
+		Events: func(m *Measurer) []*sources.Event {
+			return lo.Flatten([][]*sources.Event{
+				systemLogEvents(journal.Name, systemLogRegexes{
+					VMInit:                vmInit,
+					NetworkStart:          journalNetworkStart,
+					NetworkReady:          journalNetworkReady,
+					ContainerdStart:       journalContainerdStart,
+					ContainerdInitialized: journalContainerdInitialized,
+					KubeletStart:          journalKubeletStart,
+					KubeletInitialized:    journalKubeletInitialized,
+				}),
+				cloudInitEvents(journal.Name, syslogCloudInitRegexes),
+				m.cloudInitStageEvents(),
+				nodeadmEvents(),
+				containerEvents(journal.Name, m.podNamespace),
+			})
+		},
+		Phases: append([]*Phase{
+			{Name: "Nodeadm Config", Metric: "nodeadm_config", StartMetric: "nodeadm_config_start", EndMetric: "nodeadm_config_finish"},
+			{Name: "Nodeadm Run", Metric: "nodeadm_run", StartMetric: "nodeadm_run_start", EndMetric: "nodeadm_run_finish"},
+		}, cloudInitPhases...),
+	}
+)
+
+// nodeadmEvents are the config and run phases of nodeadm init
+func nodeadmEvents() []*sources.Event {
+	var events []*sources.Event
+	for _, event := range []struct {
+		name   string
+		metric string
+		regex  *regexp.Regexp
+	}{
+		{name: "Nodeadm Config Start", metric: "nodeadm_config_start", regex: nodeadmConfigStart},
+		{name: "Nodeadm Config Loaded", metric: "nodeadm_config_loaded", regex: nodeadmConfigLoaded},
+		{name: "Nodeadm Containerd Config Written", metric: "nodeadm_containerd_config_written", regex: nodeadmContainerdConfig},
+		{name: "Nodeadm Kubelet Config Written", metric: "nodeadm_kubelet_config_written", regex: nodeadmKubeletConfig},
+		{name: "Nodeadm Config Finish", metric: "nodeadm_config_finish", regex: nodeadmConfigFinish},
+		{name: "Nodeadm Run Start", metric: "nodeadm_run_start", regex: nodeadmRunStart},
+		{name: "Nodeadm Containerd Start", metric: "nodeadm_containerd_start", regex: nodeadmContainerdRunning},
+		{name: "Nodeadm Kubelet Start", metric: "nodeadm_kubelet_start", regex: nodeadmKubeletRunning},
+		{name: "Nodeadm Run Finish", metric: "nodeadm_run_finish", regex: nodeadmRunFinish},
+	} {
+		events = append(events, &sources.Event{
+			Name:          event.name,
+			Metric:        event.metric,
+			SrcName:       NodeadmName,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(event.regex),
+		})
+	}
+	return events
+}
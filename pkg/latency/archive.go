@@ -0,0 +1,132 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+const (
+	archiveDateLayout      = "2006-01-02"
+	archiveUnknownInstance = "unknown"
+)
+
+// Archive is a Measurement along with the raw matched lines of each timing for post-mortems
+type Archive struct {
+	Measurement *Measurement   `json:"measurement"`
+	Matches     []ArchiveMatch `json:"matches"`
+}
+
+// ArchiveMatch is the raw matched line of a timing and optionally an excerpt of the source log around the match
+type ArchiveMatch struct {
+	Event     string    `json:"event"`
+	Metric    string    `json:"metric"`
+	Source    string    `json:"src"`
+	Timestamp time.Time `json:"timestamp"`
+	Line      string    `json:"line"`
+	Excerpt   string    `json:"excerpt,omitempty"`
+}
+
+// S3Options configures how a Measurement is archived to S3 compatible object storage
+type S3Options struct {
+	// Bucket is the bucket the archive is uploaded to
+	Bucket string
+	// Cluster is the cluster name used as the key prefix
+	Cluster string
+	// Gzip compresses the archive
+	Gzip bool
+	// ExcerptLines includes this many lines before and after each match from log sources, 0 disables excerpts
+	ExcerptLines int
+}
+
+// Archive builds an Archive of the Measurement with the raw matched lines and optional excerpts of the source logs
+func (m *Measurement) Archive(excerptLines int) *Archive {
+	archive := &Archive{Measurement: m, Matches: []ArchiveMatch{}}
+	for _, t := range m.successfulTimings() {
+		if t.Provenance == nil {
+			continue
+		}
+		match := ArchiveMatch{
+			Event:     t.Event.Name,
+			Metric:    t.Event.Metric,
+			Source:    t.Event.SrcName,
+			Timestamp: t.Timestamp,
+			Line:      t.Provenance.Line,
+		}
+		if excerpter, ok := t.Event.Src.(sources.Excerpter); ok && excerptLines > 0 {
+			// excerpts are best effort since the log may have been rotated since the match
+			match.Excerpt, _ = excerpter.Excerpt(t.Provenance, excerptLines)
+		}
+		archive.Matches = append(archive.Matches, match)
+	}
+	return archive
+}
+
+// ArchiveS3 uploads an Archive of the Measurement to s3://<bucket>/<cluster>/<date>/<instance-id>.json, or .json.gz with a gzip content encoding if compressed
+func (m *Measurement) ArchiveS3(ctx context.Context, s3Client *s3.Client, opts S3Options) (string, error) {
+	if opts.Bucket == "" {
+		return "", errors.New("unable to archive measurement because the bucket is not set")
+	}
+	body, err := json.Marshal(m.Archive(opts.ExcerptLines))
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal archive: %w", err)
+	}
+	key := m.archiveKey(opts.Cluster, time.Now().UTC())
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(opts.Bucket),
+		ContentType: aws.String("application/json"),
+	}
+	if opts.Gzip {
+		var buf bytes.Buffer
+		gzWriter := gzip.NewWriter(&buf)
+		if _, err := gzWriter.Write(body); err != nil {
+			return "", fmt.Errorf("unable to gzip archive: %w", err)
+		}
+		if err := gzWriter.Close(); err != nil {
+			return "", fmt.Errorf("unable to gzip archive: %w", err)
+		}
+		body = buf.Bytes()
+		key += ".gz"
+		input.ContentEncoding = aws.String("gzip")
+	}
+	input.Key = aws.String(key)
+	input.Body = bytes.NewReader(body)
+	if _, err := s3Client.PutObject(ctx, input); err != nil {
+		return "", fmt.Errorf("unable to upload archive to s3://%s/%s: %w", opts.Bucket, key, err)
+	}
+	return fmt.Sprintf("s3://%s/%s", opts.Bucket, key), nil
+}
+
+// archiveKey constructs the object key <cluster>/<date>/<instance-id>.json
+func (m *Measurement) archiveKey(cluster string, now time.Time) string {
+	instanceID := archiveUnknownInstance
+	if m.Metadata != nil && m.Metadata.InstanceID != "" {
+		instanceID = m.Metadata.InstanceID
+	}
+	return path.Join(lo.Ternary(cluster != "", cluster, "default"), now.Format(archiveDateLayout), instanceID+".json")
+}
@@ -0,0 +1,112 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3Upload is an object uploaded to the s3Server
+type s3Upload struct {
+	path            string
+	contentType     string
+	contentEncoding string
+	body            []byte
+}
+
+// s3Server records the object of each PutObject request
+func s3Server(t *testing.T) (*s3.Client, *[]s3Upload) {
+	t.Helper()
+	var uploads []s3Upload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		uploads = append(uploads, s3Upload{
+			path:            r.URL.Path,
+			contentType:     r.Header.Get("Content-Type"),
+			contentEncoding: r.Header.Get("Content-Encoding"),
+			body:            body,
+		})
+	}))
+	t.Cleanup(server.Close)
+	client := s3.New(s3.Options{
+		Region:       "us-west-2",
+		BaseEndpoint: aws.String(server.URL),
+		UsePathStyle: true,
+		Credentials:  aws.AnonymousCredentials{},
+	})
+	return client, &uploads
+}
+
+func TestArchiveS3(t *testing.T) {
+	date := time.Now().UTC().Format(archiveDateLayout)
+	for _, tc := range []struct {
+		name            string
+		gzip            bool
+		key             string
+		contentEncoding string
+	}{
+		{name: "gzip", gzip: true, key: "prod/" + date + "/i-0123456789abcdef0.json.gz", contentEncoding: "gzip"},
+		{name: "uncompressed", gzip: false, key: "prod/" + date + "/i-0123456789abcdef0.json"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, uploads := s3Server(t)
+			measurement := &Measurement{Metadata: &Metadata{InstanceID: "i-0123456789abcdef0"}}
+			location, err := measurement.ArchiveS3(context.Background(), client, S3Options{Bucket: "archive", Cluster: "prod", Gzip: tc.gzip})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if location != "s3://archive/"+tc.key {
+				t.Errorf("expected the archive location to be s3://archive/%s, got %s", tc.key, location)
+			}
+			if len(*uploads) != 1 {
+				t.Fatalf("expected 1 upload, got %d", len(*uploads))
+			}
+			upload := (*uploads)[0]
+			if upload.path != "/archive/"+tc.key {
+				t.Errorf("expected the object to be uploaded to /archive/%s, got %s", tc.key, upload.path)
+			}
+			if upload.contentType != "application/json" || upload.contentEncoding != tc.contentEncoding {
+				t.Errorf("expected a content type of application/json and a content encoding of %q, got %q and %q", tc.contentEncoding, upload.contentType, upload.contentEncoding)
+			}
+			var body io.Reader = bytes.NewReader(upload.body)
+			if tc.gzip {
+				if body, err = gzip.NewReader(body); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var archive Archive
+			if err := json.NewDecoder(body).Decode(&archive); err != nil {
+				t.Fatalf("unable to decode the archive: %v", err)
+			}
+			if archive.Measurement == nil || archive.Measurement.Metadata.InstanceID != "i-0123456789abcdef0" {
+				t.Errorf("expected the archive to contain the measurement, got %+v", archive.Measurement)
+			}
+		})
+	}
+}
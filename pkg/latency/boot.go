@@ -0,0 +1,184 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+// Boot selector consts
+const (
+	BootSelectorCurrent  = "current"
+	BootSelectorPrevious = "previous"
+	BootSelectorAll      = "all"
+)
+
+// BootSelector selects the boots that are measured using journalctl style indexes where positive indexes
+// count from the oldest boot (1) and zero or negative indexes are relative to the current boot (0)
+type BootSelector struct {
+	// All measures every boot
+	All bool
+	// Index is the boot index, relative to the current boot unless Absolute is set
+	Index    int
+	Absolute bool
+}
+
+// ParseBootSelector parses a boot selector of current, previous, all, or a journalctl style index
+func ParseBootSelector(selector string) (BootSelector, error) {
+	switch strings.ToLower(strings.TrimSpace(selector)) {
+	case "", BootSelectorCurrent:
+		return BootSelector{}, nil
+	case BootSelectorPrevious:
+		return BootSelector{Index: -1}, nil
+	case BootSelectorAll:
+		return BootSelector{All: true}, nil
+	}
+	index, err := strconv.Atoi(strings.TrimSpace(selector))
+	if err != nil {
+		return BootSelector{}, fmt.Errorf("boot selector \"%s\" must be current, previous, all, or an index", selector)
+	}
+	return BootSelector{Index: index, Absolute: index > 0}, nil
+}
+
+// Select returns the boots matching the selector
+func (b BootSelector) Select(boots []*sources.Boot) ([]*sources.Boot, error) {
+	if b.All {
+		return boots, nil
+	}
+	index := b.Index - 1
+	if !b.Absolute {
+		_, currentIndex, ok := lo.FindIndexOf(boots, func(boot *sources.Boot) bool { return boot.Current })
+		if !ok {
+			return nil, fmt.Errorf("unable to find the current boot in %d boot(s)", len(boots))
+		}
+		index = currentIndex + b.Index
+	}
+	if index < 0 || index >= len(boots) {
+		return nil, fmt.Errorf("boot %s is out of range of the %d boot(s) found", b, len(boots))
+	}
+	return []*sources.Boot{boots[index]}, nil
+}
+
+// String is the selector in the same format ParseBootSelector accepts
+func (b BootSelector) String() string {
+	switch {
+	case b.All:
+		return BootSelectorAll
+	case !b.Absolute && b.Index == 0:
+		return BootSelectorCurrent
+	case !b.Absolute && b.Index == -1:
+		return BootSelectorPrevious
+	}
+	return strconv.Itoa(b.Index)
+}
+
+// WithBootSelector scopes boot aware sources to the selected boot so that earlier boots in the logs are not measured
+func (m *Measurer) WithBootSelector(selector BootSelector) *Measurer {
+	m.bootSelector = &selector
+	return m
+}
+
+// Boots returns the boots detected by the first boot aware source which finds any, from oldest to newest.
+// The profile's system log is tried first since sources filtered to a subset of the log see later boot starts.
+func (m *Measurer) Boots() ([]*sources.Boot, error) {
+	var errs error
+	names := lo.Keys(m.sources)
+	sort.Strings(names)
+	names = lo.Uniq(append([]string{m.Profile().SystemLog}, names...))
+	for _, name := range names {
+		scoper, ok := m.sources[name].(sources.BootScoper)
+		if !ok {
+			continue
+		}
+		boots, err := scoper.Boots()
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("unable to detect boots from source \"%s\": %w", name, err))
+			continue
+		}
+		if len(boots) != 0 {
+			return boots, nil
+		}
+	}
+	return nil, errs
+}
+
+// selectBoot returns the single boot to measure based on the boot selector, measuring all boots selects the current boot
+func (m *Measurer) selectBoot() (*sources.Boot, error) {
+	if m.bootSelector == nil {
+		return nil, nil
+	}
+	boots, err := m.Boots()
+	if err != nil {
+		return nil, err
+	}
+	// logs without boot boundaries are not scoped
+	if len(boots) == 0 {
+		return nil, nil
+	}
+	selector := *m.bootSelector
+	if selector.All {
+		selector = BootSelector{}
+	}
+	selected, err := selector.Select(boots)
+	if err != nil {
+		return nil, err
+	}
+	return selected[0], nil
+}
+
+// scopeToBoot restricts all boot aware sources to the boot, a nil boot removes the restriction
+func (m *Measurer) scopeToBoot(boot *sources.Boot) {
+	for _, src := range m.sources {
+		if scoper, ok := src.(sources.BootScoper); ok {
+			scoper.ScopeToBoot(boot)
+		}
+	}
+}
+
+// MeasureBoots produces a Measurement per boot found in the logs from oldest to newest.
+// Only the current boot is retried until its terminal events are measured or the timeout is reached, and sources
+// that are not boot aware (i.e. the EC2, IMDS, and K8s APIs) only contribute to the current boot's Measurement.
+func (m *Measurer) MeasureBoots(ctx context.Context, timeout time.Duration, retryDelay time.Duration) ([]*Measurement, error) {
+	boots, err := m.Boots()
+	if err != nil || len(boots) == 0 {
+		measurement, measureErr := m.measureUntil(ctx, timeout, retryDelay, func(ctx context.Context) *Measurement { return m.measure(ctx, nil, nil) })
+		return []*Measurement{measurement}, multierr.Append(err, measureErr)
+	}
+	var measurements []*Measurement
+	var errs error
+	for _, boot := range boots {
+		if !boot.Current {
+			measurements = append(measurements, m.measure(ctx, boot, nil))
+			continue
+		}
+		measurement, err := m.measureUntil(ctx, timeout, retryDelay, func(ctx context.Context) *Measurement { return m.measure(ctx, boot, nil) })
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("boot %d: %w", boot.Index, err))
+		}
+		measurements = append(measurements, measurement)
+	}
+	m.scopeToBoot(nil)
+	return measurements, errs
+}
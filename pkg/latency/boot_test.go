@@ -0,0 +1,151 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources/messages"
+)
+
+// bootCountingSource counts how often the boots of the messages source are detected
+type bootCountingSource struct {
+	*messages.Source
+	detections int
+}
+
+func (s *bootCountingSource) Boots() ([]*sources.Boot, error) {
+	s.detections++
+	return s.Source.Boots()
+}
+
+// withBootTime writes a kernel stat file with the boot time and an unknown boot ID for the duration of the test
+func withBootTime(t *testing.T, bootTime time.Time) {
+	t.Helper()
+	dir := t.TempDir()
+	procStatPath, bootIDPath := sources.ProcStatPath, sources.BootIDPath
+	sources.ProcStatPath, sources.BootIDPath = filepath.Join(dir, "stat"), filepath.Join(dir, "boot_id")
+	t.Cleanup(func() { sources.ProcStatPath, sources.BootIDPath = procStatPath, bootIDPath })
+	if err := os.WriteFile(sources.ProcStatPath, []byte(fmt.Sprintf("cpu  1 2 3 4\nbtime %d\n", bootTime.Unix())), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sources.BootIDPath, []byte("00000000-0000-0000-0000-000000000000\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// multiBootMeasurer measures a messages log with two logged boots while the node is running a third boot which has not been logged yet
+func multiBootMeasurer(t *testing.T) (*Measurer, *bootCountingSource, []time.Time) {
+	t.Helper()
+	start := time.Now().Add(-3 * time.Hour).Truncate(time.Second)
+	bootStarts := []time.Time{start, start.Add(time.Hour)}
+	withBootTime(t, start.Add(2*time.Hour))
+	var log strings.Builder
+	for _, bootStart := range bootStarts {
+		for i, line := range []string{"kernel: Linux version 5.10.0", "systemd[1]: Starting Kubernetes Kubelet..."} {
+			fmt.Fprintf(&log, "%s ip-192-168-0-1 %s\n", bootStart.Add(time.Duration(i)*time.Minute).In(time.Local).Format(time.Stamp), line)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "messages")
+	if err := os.WriteFile(path, []byte(log.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	src := &bootCountingSource{Source: messages.New(path)}
+	m := New().RegisterSources(src)
+	m.profile = &Profile{Name: "test", SystemLog: messages.Name}
+	if _, err := m.RegisterEvents(&sources.Event{
+		Name:          "Kubelet Start",
+		Metric:        "kubelet_start",
+		SrcName:       messages.Name,
+		MatchSelector: sources.EventMatchSelectorFirst,
+		Terminal:      true,
+		FindFn:        sources.FindByRegex(kubeletStart),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return m, src, bootStarts
+}
+
+func TestBootsAppendsTheCurrentBootWhenItHasNotBeenLogged(t *testing.T) {
+	m, _, bootStarts := multiBootMeasurer(t)
+	boots, err := m.Boots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boots) != 3 {
+		t.Fatalf("expected the 2 logged boots and the current boot, got %d", len(boots))
+	}
+	bootTime := bootStarts[1].Add(time.Hour)
+	for i, expected := range []struct {
+		start   time.Time
+		end     *time.Time
+		current bool
+	}{
+		{start: bootStarts[0], end: &bootStarts[1]},
+		{start: bootStarts[1], end: &bootTime},
+		{start: bootTime, current: true},
+	} {
+		boot := boots[i]
+		if boot.Index != i+1 || !boot.Start.Equal(expected.start) || boot.Current != expected.current ||
+			!lo.FromPtr(boot.End).Equal(lo.FromPtr(expected.end)) || (boot.End == nil) != (expected.end == nil) {
+			t.Errorf("unexpected boot %d: %+v", i+1, boot)
+		}
+	}
+}
+
+func TestMeasureUntilSelectsTheBootOnce(t *testing.T) {
+	for _, tc := range []struct {
+		selector string
+		// measured is the index of the logged boot whose kubelet start is measured, or -1 if the kubelet has not started in the boot
+		measured int
+	}{
+		{selector: BootSelectorCurrent, measured: -1},
+		{selector: BootSelectorPrevious, measured: 1},
+		{selector: "1", measured: 0},
+	} {
+		t.Run(tc.selector, func(t *testing.T) {
+			m, src, bootStarts := multiBootMeasurer(t)
+			selector, err := ParseBootSelector(tc.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			measurement, err := m.WithBootSelector(selector).MeasureUntil(context.Background(), 200*time.Millisecond, 10*time.Millisecond)
+			if src.detections != 1 {
+				t.Errorf("expected the boots to be detected once, got %d", src.detections)
+			}
+			if tc.measured == -1 {
+				if err == nil {
+					t.Fatal("expected the kubelet start to be unmeasured in the current boot")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(measurement.Timings) != 1 || !measurement.Timings[0].Timestamp.Equal(bootStarts[tc.measured].Add(time.Minute)) {
+				t.Errorf("expected the kubelet start of boot %d, got %+v", tc.measured+1, measurement.Timings)
+			}
+		})
+	}
+}
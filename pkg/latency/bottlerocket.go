@@ -0,0 +1,108 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"regexp"
+
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources/journal"
+)
+
+var (
+	// BottlerocketEarlyBootName is the name of the journal source restricted to the Bottlerocket early boot units
+	BottlerocketEarlyBootName = "Bottlerocket Early Boot"
+	// bottlerocketEarlyBootUnits apply the user data settings and render the config files before containerd and kubelet start
+	bottlerocketEarlyBootUnits = []string{"early-boot-config.service", "settings-applier.service"}
+
+	// older systemd versions only log the unit description (i.e. "Starting Bottlerocket userdata configuration system...")
+	earlyBootConfigStart  = regexp.MustCompile(`.*systemd\[[0-9]+\]: Starting (early-boot-config\.service|Bottlerocket userdata configuration system).*`)
+	earlyBootConfigFinish = regexp.MustCompile(`.*systemd\[[0-9]+\]: (Finished|Started) (early-boot-config\.service|Bottlerocket userdata configuration system).*`)
+	settingsApplierStart  = regexp.MustCompile(`.*systemd\[[0-9]+\]: Starting (settings-applier\.service|Applies settings to create config files).*`)
+	settingsApplierFinish = regexp.MustCompile(`.*systemd\[[0-9]+\]: (Finished|Started) (settings-applier\.service|Applies settings to create config files).*`)
+
+	// ProfileBottlerocket is the Bottlerocket profile which reads the journal since Bottlerocket does not have a syslog
+	ProfileBottlerocket = &Profile{
+		Name: "bottlerocket",
+		Detect: func(osRelease map[string]string) bool {
+			return osRelease["ID"] == "bottlerocket"
+		},
+		SystemLog: journal.Name,
+		Sources: func(m *Measurer) []sources.Source {
+			journalPath := journal.DefaultPath()
+			// the unit sources share the journal's reader so that the journal files are only parsed once
+			journalReader := journal.NewReader()
+			return []sources.Source{
+				journal.New(journalPath).WithReader(journalReader).WithCurrentBootOnly(m.currentBootOnly),
+				journal.NewForUnits(BottlerocketEarlyBootName, journalPath, bottlerocketEarlyBootUnits...).WithReader(journalReader).WithCurrentBootOnly(m.currentBootOnly),
+			}
+		},
+		Events: func(m *Measurer) []*sources.Event {
+			return lo.Flatten([][]*sources.Event{
+				systemLogEvents(journal.Name, systemLogRegexes{
+					VMInit:                vmInit,
+					NetworkStart:          journalNetworkStart,
+					NetworkReady:          journalNetworkReady,
+					ContainerdStart:       journalContainerdStart,
+					ContainerdInitialized: journalContainerdInitialized,
+					KubeletStart:          journalKubeletStart,
+					KubeletInitialized:    journalKubeletInitialized,
+				}),
+				bottlerocketEarlyBootEvents(),
+				containerEvents(journal.Name, m.podNamespace),
+			})
+		},
+		Phases: []*Phase{
+			{Name: "Early Boot Config", Metric: "early_boot_config", StartMetric: "early_boot_config_start", EndMetric: "early_boot_config_finish"},
+			{Name: "Settings Applier", Metric: "settings_applier", StartMetric: "settings_applier_start", EndMetric: "settings_applier_finish"},
+		},
+	}
+)
+
+// bottlerocketEarlyBootEvents are the start and finish of the Bottlerocket units which apply the user data settings
+func bottlerocketEarlyBootEvents() []*sources.Event {
+	return []*sources.Event{
+		{
+			Name:          "Early Boot Config Start",
+			Metric:        "early_boot_config_start",
+			SrcName:       BottlerocketEarlyBootName,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(earlyBootConfigStart),
+		},
+		{
+			Name:          "Early Boot Config Finish",
+			Metric:        "early_boot_config_finish",
+			SrcName:       BottlerocketEarlyBootName,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(earlyBootConfigFinish),
+		},
+		{
+			Name:          "Settings Applier Start",
+			Metric:        "settings_applier_start",
+			SrcName:       BottlerocketEarlyBootName,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(settingsApplierStart),
+		},
+		{
+			Name:          "Settings Applier Finish",
+			Metric:        "settings_applier_finish",
+			SrcName:       BottlerocketEarlyBootName,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(settingsApplierFinish),
+		},
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+const (
+	// sourceBackoffBase is the backoff after a source's first failed timing run
+	sourceBackoffBase = 5 * time.Second
+	// sourceBackoffMax caps the exponential backoff of a failing source
+	sourceBackoffMax = 2 * time.Minute
+)
+
+// resultCache holds event timings across the timing runs of the current boot and the backoff state of failing sources
+// so that resolved events and throttled APIs are not queried on every retry
+type resultCache struct {
+	mu sync.Mutex
+	// timings are the timings of the last run by event name
+	timings map[string][]*sources.Timing
+	// resolved are the events whose timings will not change across runs
+	resolved map[string]bool
+	backoffs map[string]*sourceBackoff
+}
+
+// sourceBackoff tracks the consecutive failed runs of a source
+type sourceBackoff struct {
+	failures int
+	retryAt  time.Time
+}
+
+// resolvedTimings returns copies of the cached timings of an event if the event is resolved
+func (c *resultCache) resolvedTimings(event *sources.Event) ([]*sources.Timing, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.resolved[event.Name] {
+		return nil, false
+	}
+	return copyTimings(c.timings[event.Name]), true
+}
+
+// lastTimings returns copies of the timings of an event from the last run
+func (c *resultCache) lastTimings(event *sources.Event) []*sources.Timing {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return copyTimings(c.timings[event.Name])
+}
+
+// backingOff returns true if the source failed recently and should not be queried until its backoff expires
+func (c *resultCache) backingOff(srcName string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	backoff, ok := c.backoffs[srcName]
+	return ok && now.Before(backoff.retryAt)
+}
+
+// record caches the timings of a source's events and backs off the source with jitter if an API call failed or timed out
+func (c *resultCache) record(srcName string, events []*sources.Event, eventTimings [][]*sources.Timing, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timings == nil {
+		c.timings = map[string][]*sources.Timing{}
+		c.resolved = map[string]bool{}
+		c.backoffs = map[string]*sourceBackoff{}
+	}
+	failed := false
+	for i, event := range events {
+		timings := eventTimings[i]
+		c.timings[event.Name] = copyTimings(timings)
+		successful := len(timings) != 0 && lo.EveryBy(timings, func(t *sources.Timing) bool { return t.Error == nil })
+		// only the first match is stable since later matches may still be logged
+		c.resolved[event.Name] = successful && event.MatchSelector == sources.EventMatchSelectorFirst
+		failed = failed || lo.ContainsBy(timings, func(t *sources.Timing) bool {
+			category := sources.ErrorCategory(t.Error)
+			return category == sources.ErrorCategoryAPI || category == sources.ErrorCategoryTimeout
+		})
+	}
+	if !failed {
+		delete(c.backoffs, srcName)
+		return
+	}
+	backoff, ok := c.backoffs[srcName]
+	if !ok {
+		backoff = &sourceBackoff{}
+		c.backoffs[srcName] = backoff
+	}
+	backoff.failures++
+	backoff.retryAt = backoffRetryAt(now, backoff.failures)
+}
+
+// backoffRetryAt returns when to retry after consecutive failures, the delay doubles from sourceBackoffBase up to sourceBackoffMax
+func backoffRetryAt(now time.Time, failures int) time.Time {
+	delay := sourceBackoffMax
+	if shift := failures - 1; shift < 16 {
+		delay = min(sourceBackoffBase<<shift, sourceBackoffMax)
+	}
+	// jitter spreads the retries of many nodes failing at the same time
+	return now.Add(delay/2 + rand.N(delay/2+1))
+}
+
+// copyTimings returns shallow copies of the timings since T is recomputed for every Measurement
+func copyTimings(timings []*sources.Timing) []*sources.Timing {
+	return lo.Map(timings, func(t *sources.Timing, _ int) *sources.Timing {
+		timing := *t
+		return &timing
+	})
+}
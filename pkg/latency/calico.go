@@ -0,0 +1,81 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"regexp"
+
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources/calico"
+)
+
+var (
+	// calico-node logs the startup, Felix, and confd lines with the component's file (i.e. [INFO][63] felix/int_dataplane.go 1873: Completed first update to dataplane.)
+	calicoNodeStart           = regexp.MustCompile(`.*startup/startup\.go [0-9]+: Early log level set to .*`)
+	calicoFelixInSync         = regexp.MustCompile(`.*felix/\S+ [0-9]+: Datastore (now )?in sync.*`)
+	calicoDataplaneProgrammed = regexp.MustCompile(`.*felix/\S+ [0-9]+: Completed first update to dataplane.*`)
+	// BIRD is logged by the bird and bird6 runit services (i.e. bird: BIRD 1.6.8 ready.)
+	calicoBIRDReady = regexp.MustCompile(`.*bird6?: BIRD [0-9.]+ ready.*`)
+
+	// CNIProfileCalico is the Calico profile which reads the calico-node pod log
+	CNIProfileCalico = &CNIProfile{
+		Name: "calico",
+		Detect: func(pluginTypes []string) bool {
+			return lo.Contains(pluginTypes, "calico")
+		},
+		Sources: func(m *Measurer) []sources.Source {
+			return []sources.Source{
+				calico.New(calico.DefaultPath).WithCurrentBootOnly(m.currentBootOnly),
+			}
+		},
+		Events: func(_ *Measurer) []*sources.Event {
+			return []*sources.Event{
+				{
+					Name:          "Calico Node Start",
+					Metric:        "calico_node_start",
+					SrcName:       calico.Name,
+					MatchSelector: sources.EventMatchSelectorFirst,
+					FindFn:        sources.FindByRegex(calicoNodeStart),
+				},
+				{
+					Name:          "Calico Felix In Sync",
+					Metric:        "calico_felix_in_sync",
+					SrcName:       calico.Name,
+					MatchSelector: sources.EventMatchSelectorFirst,
+					FindFn:        sources.FindByRegex(calicoFelixInSync),
+				},
+				{
+					Name:          "Calico Dataplane Programmed",
+					Metric:        "calico_dataplane_programmed",
+					SrcName:       calico.Name,
+					MatchSelector: sources.EventMatchSelectorFirst,
+					FindFn:        sources.FindByRegex(calicoDataplaneProgrammed),
+				},
+				{
+					Name:          "Calico BIRD Ready",
+					Metric:        "calico_bird_ready",
+					SrcName:       calico.Name,
+					MatchSelector: sources.EventMatchSelectorFirst,
+					FindFn:        sources.FindByRegex(calicoBIRDReady),
+				},
+			}
+		},
+		Phases: []*Phase{
+			{Name: "Calico Felix Startup", Metric: "calico_felix_startup", StartMetric: "calico_node_start", EndMetric: "calico_dataplane_programmed"},
+		},
+	}
+)
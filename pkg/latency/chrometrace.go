@@ -0,0 +1,128 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"encoding/json"
+)
+
+// Chrome Trace Event Format consts
+// https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU
+const (
+	traceEventPhaseInstant  = "i"
+	traceEventPhaseComplete = "X"
+	traceEventPhaseMetadata = "M"
+
+	traceEventScopeProcess = "p"
+	tracePhasesProcessName = "Phases"
+)
+
+// ChromeTrace is a Measurement in the Chrome Trace Event Format which can be opened in ui.perfetto.dev or chrome://tracing
+type ChromeTrace struct {
+	TraceEvents     []ChromeTraceEvent `json:"traceEvents"`
+	DisplayTimeUnit string             `json:"displayTimeUnit"`
+	OtherData       *Metadata          `json:"otherData,omitempty"`
+}
+
+// ChromeTraceEvent is a single event in the Chrome Trace Event Format
+type ChromeTraceEvent struct {
+	Name     string         `json:"name"`
+	Category string         `json:"cat,omitempty"`
+	Phase    string         `json:"ph"`
+	TS       int64          `json:"ts"`
+	Duration *int64         `json:"dur,omitempty"`
+	PID      int            `json:"pid"`
+	TID      int            `json:"tid"`
+	Scope    string         `json:"s,omitempty"`
+	Args     map[string]any `json:"args,omitempty"`
+}
+
+// ChromeTrace converts a Measurement to the Chrome Trace Event Format.
+// Each source is represented as a process with instant events for its timings.
+// Derived phases are complete events in a separate process with a thread per phase since phases may partially overlap.
+func (m *Measurement) ChromeTrace() *ChromeTrace {
+	trace := &ChromeTrace{
+		TraceEvents:     []ChromeTraceEvent{},
+		DisplayTimeUnit: "ms",
+		OtherData:       m.Metadata,
+	}
+	pids := map[string]int{}
+	pidFor := func(processName string) int {
+		if pid, ok := pids[processName]; ok {
+			return pid
+		}
+		pid := len(pids) + 1
+		pids[processName] = pid
+		trace.TraceEvents = append(trace.TraceEvents,
+			ChromeTraceEvent{Name: "process_name", Phase: traceEventPhaseMetadata, PID: pid, Args: map[string]any{"name": processName}},
+			ChromeTraceEvent{Name: "process_sort_index", Phase: traceEventPhaseMetadata, PID: pid, Args: map[string]any{"sort_index": pid}},
+		)
+		return pid
+	}
+	for _, t := range m.Timings {
+		if t.Error != nil {
+			continue
+		}
+		args := map[string]any{
+			"metric":  t.Event.Metric,
+			"seconds": t.T.Seconds(),
+		}
+		if t.Comment != "" {
+			args["comment"] = t.Comment
+		}
+		trace.TraceEvents = append(trace.TraceEvents, ChromeTraceEvent{
+			Name:     t.Event.Name,
+			Category: t.Event.SrcName,
+			Phase:    traceEventPhaseInstant,
+			TS:       t.Timestamp.UnixMicro(),
+			PID:      pidFor(t.Event.SrcName),
+			TID:      1,
+			Scope:    traceEventScopeProcess,
+			Args:     args,
+		})
+	}
+	for i, pt := range m.PhaseTimings() {
+		duration := pt.Duration.Microseconds()
+		pid := pidFor(tracePhasesProcessName)
+		trace.TraceEvents = append(trace.TraceEvents,
+			ChromeTraceEvent{Name: "thread_name", Phase: traceEventPhaseMetadata, PID: pid, TID: i + 1, Args: map[string]any{"name": pt.Phase.Name}},
+		)
+		args := map[string]any{
+			"metric":  pt.Phase.Metric,
+			"start":   pt.Phase.StartMetric,
+			"end":     pt.Phase.EndMetric,
+			"seconds": pt.Duration.Seconds(),
+		}
+		if pt.Phase.CommentDimension != "" {
+			args[pt.Phase.CommentDimension] = pt.Comment
+		}
+		trace.TraceEvents = append(trace.TraceEvents, ChromeTraceEvent{
+			Name:     pt.Phase.Name,
+			Category: tracePhasesProcessName,
+			Phase:    traceEventPhaseComplete,
+			TS:       pt.Start.UnixMicro(),
+			Duration: &duration,
+			PID:      pid,
+			TID:      i + 1,
+			Args:     args,
+		})
+	}
+	return trace
+}
+
+// MarshalChromeTrace marshals a Measurement to Chrome Trace Event Format JSON
+func (m *Measurement) MarshalChromeTrace() ([]byte, error) {
+	return json.Marshal(m.ChromeTrace())
+}
@@ -0,0 +1,77 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"regexp"
+
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources/cilium"
+)
+
+var (
+	// cilium-agent logs in logfmt (i.e. level=info msg="Daemon initialization completed" bootstrapTime=5.1s subsys=daemon)
+	ciliumAgentStart          = regexp.MustCompile(`.*msg="Cilium [0-9]+\.[0-9]+\.[0-9]+.*`)
+	ciliumDaemonInitialized   = regexp.MustCompile(`.*msg="Daemon initialization completed".*`)
+	ciliumBootstrapTime       = regexp.MustCompile(`bootstrapTime=(\S+)`)
+	ciliumEndpointRegenerated = regexp.MustCompile(`.*msg="Rewrote endpoint BPF program".*`)
+	// the health and host endpoints are regenerated without a pod (i.e. k8sPodName=/)
+	ciliumEndpointPod = regexp.MustCompile(`k8sPodName=([^/\s]+/\S+)`)
+
+	// CNIProfileCilium is the Cilium profile which reads the cilium-agent pod log
+	CNIProfileCilium = &CNIProfile{
+		Name: "cilium",
+		Detect: func(pluginTypes []string) bool {
+			return lo.Contains(pluginTypes, "cilium-cni")
+		},
+		Sources: func(m *Measurer) []sources.Source {
+			return []sources.Source{
+				cilium.New(cilium.DefaultPath).WithCurrentBootOnly(m.currentBootOnly),
+			}
+		},
+		Events: func(_ *Measurer) []*sources.Event {
+			return []*sources.Event{
+				{
+					Name:          "Cilium Agent Start",
+					Metric:        "cilium_agent_start",
+					SrcName:       cilium.Name,
+					MatchSelector: sources.EventMatchSelectorFirst,
+					FindFn:        sources.FindByRegex(ciliumAgentStart),
+				},
+				{
+					Name:          "Cilium Agent Initialized",
+					Metric:        "cilium_agent_initialized",
+					SrcName:       cilium.Name,
+					MatchSelector: sources.EventMatchSelectorFirst,
+					CommentFn:     sources.CommentSubmatch(ciliumBootstrapTime),
+					FindFn:        sources.FindByRegex(ciliumDaemonInitialized),
+				},
+				{
+					Name:          "Cilium Endpoint Regenerated",
+					Metric:        "cilium_endpoint_regenerated",
+					SrcName:       cilium.Name,
+					MatchSelector: sources.EventMatchSelectorAll,
+					CommentFn:     sources.CommentSubmatch(ciliumEndpointPod),
+					FindFn:        sources.FindByRegex(ciliumEndpointRegenerated),
+				},
+			}
+		},
+		Phases: []*Phase{
+			{Name: "Cilium Agent Initialization", Metric: "cilium_agent_initialization", StartMetric: "cilium_agent_start", EndMetric: "cilium_agent_initialized"},
+		},
+	}
+)
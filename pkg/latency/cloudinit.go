@@ -0,0 +1,152 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"regexp"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources/cloudinit"
+)
+
+// cloudInitRegexes are the regexes of the cloud-init stage events which are logged differently to the syslog and cloud-init.log
+type cloudInitRegexes struct {
+	InitialStart *regexp.Regexp
+	ConfigStart  *regexp.Regexp
+	FinalStart   *regexp.Regexp
+	FinalFinish  *regexp.Regexp
+}
+
+var (
+	// syslogCloudInitRegexes match the cloud-init stages logged to the syslog or journal
+	syslogCloudInitRegexes = cloudInitRegexes{
+		InitialStart: cloudInitInitialStart,
+		ConfigStart:  cloudInitConfigStart,
+		FinalStart:   cloudInitFinalStart,
+		FinalFinish:  cloudInitFinalFinish,
+	}
+	// cloud-init.log lines are logged by cloud-init's modules (i.e. "2024-01-01 00:00:00,000 - util.py[DEBUG]: Cloud-init v. 23.4 running 'init' at ...")
+	cloudInitLogRegexes = cloudInitRegexes{
+		InitialStart: regexp.MustCompile(`.*Cloud-init v\. \S+ running 'init' .*`),
+		ConfigStart:  regexp.MustCompile(`.*Cloud-init v\. \S+ running 'modules:config' .*`),
+		FinalStart:   regexp.MustCompile(`.*Cloud-init v\. \S+ running 'modules:final' .*`),
+		FinalFinish:  regexp.MustCompile(`.*Cloud-init v\. \S+ finished at .*`),
+	}
+	// cloud-init reports the start and finish of each module with the stage (i.e. "start: modules-final/config-scripts_user: running config-scripts_user ...")
+	cloudInitModuleStart  = regexp.MustCompile(`.*handlers\.py\[DEBUG\]: start: ([a-z-]+/config-[^:\s]+): .*`)
+	cloudInitModuleFinish = regexp.MustCompile(`.*handlers\.py\[DEBUG\]: finish: ([a-z-]+/config-[^:\s]+): .*`)
+
+	// cloudInitPhases are a phase per cloud-init stage and module emitted as the cloudinit_stage_duration and cloudinit_module_duration metrics
+	// with a stage or module dimension (i.e. module=modules-final/config-scripts_user)
+	cloudInitPhases = []*Phase{
+		{Name: "Cloud-Init Stage", Metric: "cloudinit_stage", StartMetric: "cloudinit_stage_start", EndMetric: "cloudinit_stage_finish", CommentDimension: "stage"},
+		{Name: "Cloud-Init Module", Metric: "cloudinit_module", StartMetric: "cloudinit_module_start", EndMetric: "cloudinit_module_finish", CommentDimension: "module"},
+	}
+)
+
+// cloudInitSources are the cloud-init.log and the status.json and result.json sources
+func cloudInitSources(m *Measurer) []sources.Source {
+	return []sources.Source{
+		cloudinit.New(cloudinit.DefaultPath).WithCurrentBootOnly(m.currentBootOnly),
+		cloudinit.NewStatus(cloudinit.DefaultStatusPath, cloudinit.DefaultResultPath),
+	}
+}
+
+// cloudInitEvents are the cloud-init stage events
+func cloudInitEvents(srcName string, regexes cloudInitRegexes) []*sources.Event {
+	return []*sources.Event{
+		{
+			Name:          "Cloud-Init Initial Start",
+			Metric:        "cloudinit_initial_start",
+			SrcName:       srcName,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(regexes.InitialStart),
+		},
+		{
+			Name:          "Cloud-Init Config Start",
+			Metric:        "cloudinit_config_start",
+			SrcName:       srcName,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(regexes.ConfigStart),
+		},
+		{
+			Name:          "Cloud-Init Final Start",
+			Metric:        "cloudinit_final_start",
+			SrcName:       srcName,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(regexes.FinalStart),
+		},
+		{
+			Name:          "Cloud-Init Final Finish",
+			Metric:        "cloudinit_final_finish",
+			SrcName:       srcName,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(regexes.FinalFinish),
+		},
+	}
+}
+
+// cloudInitStageEvents are the start and finish of every cloud-init stage and module commented with its name, and the cloud-init result
+func (m *Measurer) cloudInitStageEvents() []*sources.Event {
+	events := []*sources.Event{
+		{
+			Name:          "Cloud-Init Module Start",
+			Metric:        "cloudinit_module_start",
+			SrcName:       cloudinit.Name,
+			MatchSelector: sources.EventMatchSelectorAll,
+			CommentFn:     sources.CommentSubmatch(cloudInitModuleStart),
+			FindFn:        sources.FindByRegex(cloudInitModuleStart),
+		},
+		{
+			Name:          "Cloud-Init Module Finish",
+			Metric:        "cloudinit_module_finish",
+			SrcName:       cloudinit.Name,
+			MatchSelector: sources.EventMatchSelectorAll,
+			CommentFn:     sources.CommentSubmatch(cloudInitModuleFinish),
+			FindFn:        sources.FindByRegex(cloudInitModuleFinish),
+		},
+	}
+	src, ok := m.GetSource(cloudinit.StatusName)
+	if !ok {
+		return events
+	}
+	status := src.(*cloudinit.StatusSource)
+	return append(events, []*sources.Event{
+		{
+			Name:          "Cloud-Init Stage Start",
+			Metric:        "cloudinit_stage_start",
+			SrcName:       cloudinit.StatusName,
+			MatchSelector: sources.EventMatchSelectorAll,
+			CommentFn:     cloudinit.CommentValue(),
+			FindFn:        status.FindStageStarts(),
+		},
+		{
+			Name:          "Cloud-Init Stage Finish",
+			Metric:        "cloudinit_stage_finish",
+			SrcName:       cloudinit.StatusName,
+			MatchSelector: sources.EventMatchSelectorAll,
+			CommentFn:     cloudinit.CommentValue(),
+			FindFn:        status.FindStageFinishes(),
+		},
+		{
+			Name:          "Cloud-Init Result",
+			Metric:        "cloudinit_result",
+			SrcName:       cloudinit.StatusName,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			CommentFn:     cloudinit.CommentValue(),
+			FindFn:        status.FindResult(),
+		},
+	}...)
+}
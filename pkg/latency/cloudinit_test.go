@@ -0,0 +1,89 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources/cloudinit"
+)
+
+// cloudInitDir is the fixture of a cloud-init run whose user data script takes 40s
+const cloudInitDir = "../../test/cloudinit"
+
+func TestCloudInitPhasesAreEmittedWithTheirDimension(t *testing.T) {
+	m := New().RegisterSources(
+		cloudinit.New(cloudInitDir+"/var/log/cloud-init.log"),
+		cloudinit.NewStatus(cloudInitDir+"/run/cloud-init/status.json", cloudInitDir+"/run/cloud-init/result.json"),
+	).RegisterPhases(cloudInitPhases...)
+	if _, err := m.RegisterEvents(m.cloudInitStageEvents()...); err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	m.Measure(context.Background()).RegisterMetrics(registry, "test")
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		metric    string
+		dimension string
+		expected  map[string]float64
+	}{
+		{
+			metric:    "cloudinit_stage_duration",
+			dimension: "stage",
+			expected:  map[string]float64{"init-local": 2.5, "init": 3.5, "modules-config": 0.9, "modules-final": 41},
+		},
+		{
+			metric:    "cloudinit_module_duration",
+			dimension: "module",
+			expected: map[string]float64{
+				"init-network/config-users_groups":  0.5,
+				"modules-config/config-runcmd":      0.5,
+				"modules-final/config-scripts_user": 40,
+			},
+		},
+	} {
+		t.Run(tc.metric, func(t *testing.T) {
+			family, ok := lo.Find(families, func(f *dto.MetricFamily) bool { return f.GetName() == tc.metric })
+			if !ok {
+				t.Fatalf("expected the %s metric to be emitted", tc.metric)
+			}
+			durations := map[string]float64{}
+			for _, metric := range family.GetMetric() {
+				label, ok := lo.Find(metric.GetLabel(), func(l *dto.LabelPair) bool { return l.GetName() == tc.dimension })
+				if !ok {
+					t.Fatalf("expected the %s metric to have a %s dimension, got %v", tc.metric, tc.dimension, metric.GetLabel())
+				}
+				durations[label.GetValue()] = metric.GetGauge().GetValue()
+			}
+			if len(durations) != len(tc.expected) {
+				t.Errorf("expected %d %s dimension values, got %v", len(tc.expected), tc.dimension, durations)
+			}
+			for value, expected := range tc.expected {
+				if math.Abs(durations[value]-expected) > 0.001 {
+					t.Errorf("expected %s %s=%s to be %.3fs, got %.3fs", tc.metric, tc.dimension, value, expected, durations[value])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+const (
+	// DefaultCloudWatchNamespace is the CloudWatch namespace metrics are emitted to if one is not specified
+	DefaultCloudWatchNamespace = "KubernetesNodeLatency"
+	// MaxCloudWatchDimensions is the CloudWatch limit of dimensions per metric
+	MaxCloudWatchDimensions = 30
+	// maxMetricDataPerRequest is the PutMetricData limit of metrics per request
+	maxMetricDataPerRequest = 1000
+	// maxEMFMetricsPerDirective is the Embedded Metric Format limit of metrics per metric directive
+	maxEMFMetricsPerDirective = 100
+	// highResolutionStorage is the StorageResolution in seconds for high-resolution CloudWatch metrics
+	highResolutionStorage = 1
+)
+
+// CloudWatchOptions configures how a Measurement is emitted as CloudWatch metrics
+type CloudWatchOptions struct {
+	// Namespace is the CloudWatch metric namespace, defaults to DefaultCloudWatchNamespace
+	Namespace string
+	// ExperimentDimension is the value of the experiment dimension
+	ExperimentDimension string
+	// Dimensions are extra dimensions added to every metric
+	Dimensions map[string]string
+	// HighResolution stores metrics with 1 second resolution instead of the standard 60 seconds
+	HighResolution bool
+}
+
+// EMF is the CloudWatch Embedded Metric Format metadata object
+// https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html
+type EMF struct {
+	Timestamp         int64                `json:"Timestamp"`
+	CloudWatchMetrics []EMFMetricDirective `json:"CloudWatchMetrics"`
+}
+
+// EMFMetricDirective instructs CloudWatch which root node members of an EMF log line are metrics and dimensions
+type EMFMetricDirective struct {
+	Namespace  string                `json:"Namespace"`
+	Dimensions [][]string            `json:"Dimensions"`
+	Metrics    []EMFMetricDefinition `json:"Metrics"`
+}
+
+// EMFMetricDefinition defines a metric within an EMF metric directive
+type EMFMetricDefinition struct {
+	Name              string `json:"Name"`
+	Unit              string `json:"Unit"`
+	StorageResolution int    `json:"StorageResolution,omitempty"`
+}
+
+// EmitCloudWatchMetrics posts metric data to CloudWatch based on a Measurement.
+// Metric data is batched up to the PutMetricData limit of metrics per request.
+func (m *Measurement) EmitCloudWatchMetrics(ctx context.Context, cw *cloudwatch.Client, opts CloudWatchOptions) error {
+	dimensions := m.cloudWatchDimensions(opts)
+	var storageResolution *int32
+	if opts.HighResolution {
+		storageResolution = aws.Int32(highResolutionStorage)
+	}
+	metricData := lo.Map(m.metricValues(), func(value metricValue, _ int) types.MetricDatum {
+		valueDimensions := lo.MapToSlice(lo.Assign(dimensions, value.dimensions), func(k, v string) types.Dimension {
+			return types.Dimension{
+				Name:  aws.String(k),
+				Value: aws.String(v),
+			}
+		})
+		return types.MetricDatum{
+			MetricName:        aws.String(value.name),
+			Value:             aws.Float64(value.seconds),
+			Unit:              types.StandardUnitSeconds,
+			Dimensions:        valueDimensions,
+			StorageResolution: storageResolution,
+		}
+	})
+	var errs error
+	for _, batch := range lo.Chunk(metricData, maxMetricDataPerRequest) {
+		if _, err := cw.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(opts.namespace()),
+			MetricData: batch,
+		}); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+// WriteEMF writes a Measurement as CloudWatch Embedded Metric Format (EMF) log lines which can be ingested by the CloudWatch agent or Fluent Bit.
+// Each line holds up to the EMF limit of metrics with the same dimensions and timings with the same metric are emitted as an array of values.
+func (m *Measurement) WriteEMF(w io.Writer, opts CloudWatchOptions) error {
+	storageResolution := 0
+	if opts.HighResolution {
+		storageResolution = highResolutionStorage
+	}
+	timestamp := time.Now().UnixMilli()
+	encoder := json.NewEncoder(w)
+	// metrics with extra dimensions (i.e. the module of a cloud-init module phase) are written to a line per dimension value
+	for _, group := range lo.PartitionBy(m.metricValues(), func(value metricValue) string { return fmt.Sprint(value.dimensions) }) {
+		dimensions := lo.Assign(m.cloudWatchDimensions(opts), group[0].dimensions)
+		dimensionKeys := lo.Keys(dimensions)
+		sort.Strings(dimensionKeys)
+		values := map[string][]float64{}
+		var metrics []string
+		for _, value := range group {
+			if _, ok := values[value.name]; !ok {
+				metrics = append(metrics, value.name)
+			}
+			values[value.name] = append(values[value.name], value.seconds)
+		}
+		for _, batch := range lo.Chunk(metrics, maxEMFMetricsPerDirective) {
+			line := map[string]any{}
+			for k, v := range dimensions {
+				line[k] = v
+			}
+			for _, metric := range batch {
+				if len(values[metric]) == 1 {
+					line[metric] = values[metric][0]
+				} else {
+					line[metric] = values[metric]
+				}
+			}
+			line["_aws"] = EMF{
+				Timestamp: timestamp,
+				CloudWatchMetrics: []EMFMetricDirective{{
+					Namespace:  opts.namespace(),
+					Dimensions: [][]string{dimensionKeys},
+					Metrics: lo.Map(batch, func(metric string, _ int) EMFMetricDefinition {
+						return EMFMetricDefinition{
+							Name:              metric,
+							Unit:              string(types.StandardUnitSeconds),
+							StorageResolution: storageResolution,
+						}
+					}),
+				}},
+			}
+			if err := encoder.Encode(line); err != nil {
+				return fmt.Errorf("unable to write EMF log line: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// cloudWatchDimensions merges the default metric dimensions with any extra dimensions from the CloudWatch options
+func (m *Measurement) cloudWatchDimensions(opts CloudWatchOptions) map[string]string {
+	return lo.Assign(m.metricDimensions(opts.ExperimentDimension), opts.Dimensions)
+}
+
+// successfulTimings filters out timings with errors since they do not have a valid timestamp to emit
+func (m *Measurement) successfulTimings() []*sources.Timing {
+	return lo.Filter(m.Timings, func(t *sources.Timing, _ int) bool { return t.Error == nil })
+}
+
+// namespace returns the configured CloudWatch namespace or the default
+func (o CloudWatchOptions) namespace() string {
+	if o.Namespace == "" {
+		return DefaultCloudWatchNamespace
+	}
+	return o.Namespace
+}
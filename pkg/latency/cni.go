@@ -0,0 +1,191 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources/awsnode"
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources/ipamd"
+)
+
+// CNIProfile is the log sources, events, and phases of a CNI
+type CNIProfile struct {
+	// Name selects the CNI profile (i.e. vpc-cni, cilium, or calico)
+	Name string
+	// Detect returns true if the profile is for one of the plugin types of the active CNI config
+	Detect func(pluginTypes []string) bool
+	// Sources returns the log sources of the CNI
+	Sources func(m *Measurer) []sources.Source
+	// Events returns the events of the CNI's sources
+	Events func(m *Measurer) []*sources.Event
+	// Phases are derived from the CNI's events in addition to the DefaultPhases
+	Phases []*Phase
+}
+
+var (
+	// CNIConfDir is the directory of the CNI config files which is read to detect the CNI
+	CNIConfDir = "/etc/cni/net.d"
+
+	// CNIProfileVPC is the Amazon VPC CNI profile, which is detected from the aws-cni plugin
+	CNIProfileVPC = &CNIProfile{
+		Name: "vpc-cni",
+		Detect: func(pluginTypes []string) bool {
+			return lo.Contains(pluginTypes, "aws-cni")
+		},
+		Sources: func(m *Measurer) []sources.Source {
+			return []sources.Source{
+				awsnode.New(awsnode.DefaultPath).WithCurrentBootOnly(m.currentBootOnly),
+				ipamd.New(ipamd.DefaultPath).WithCurrentBootOnly(m.currentBootOnly),
+				ipamd.NewPlugin(ipamd.DefaultPluginPath).WithCurrentBootOnly(m.currentBootOnly),
+			}
+		},
+		Events: func(m *Measurer) []*sources.Event {
+			return append([]*sources.Event{
+				{
+					Name:          "VPC CNI Init Start",
+					Metric:        "vpc_cni_init_start",
+					SrcName:       m.Profile().SystemLog,
+					MatchSelector: sources.EventMatchSelectorFirst,
+					FindFn:        sources.FindByRegex(vpcCNIInitStart),
+				},
+				{
+					Name:          "AWS Node Start",
+					Metric:        "aws_node_start",
+					SrcName:       m.Profile().SystemLog,
+					MatchSelector: sources.EventMatchSelectorFirst,
+					FindFn:        sources.FindByRegex(awsNodeStart),
+				},
+				{
+					Name:          "VPC CNI Plugin Initialized",
+					Metric:        "vpc_cni_plugin_initialized",
+					SrcName:       awsnode.Name,
+					MatchSelector: sources.EventMatchSelectorFirst,
+					FindFn:        sources.FindByRegex(vpcCNIInitialized),
+				},
+			}, ipamdEvents()...)
+		},
+		Phases: []*Phase{
+			{Name: "VPC CNI Initialization", Metric: "vpc_cni_initialization", StartMetric: "vpc_cni_init_start", EndMetric: "vpc_cni_plugin_initialized"},
+			{Name: "IPAMD Initialization", Metric: "ipamd_initialization", StartMetric: "ipamd_start", EndMetric: "ipamd_warm_pool_ready"},
+		},
+	}
+	// CNIProfiles are the built-in CNI profiles, chained CNIs (i.e. Cilium on the VPC CNI) match more than one
+	CNIProfiles = []*CNIProfile{CNIProfileVPC, CNIProfileCilium, CNIProfileCalico}
+)
+
+// cniConfig is the subset of a CNI .conf or .conflist file needed to detect the CNI
+type cniConfig struct {
+	Type    string      `json:"type"`
+	Plugins []cniConfig `json:"plugins"`
+}
+
+// ParseCNIPluginTypes returns the plugin types of the active CNI config, which is the first config file in the directory by name like the container runtime uses
+func ParseCNIPluginTypes(confDir string) ([]string, error) {
+	entries, err := os.ReadDir(confDir)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := lo.Find(entries, func(entry os.DirEntry) bool {
+		return !entry.IsDir() && lo.Contains([]string{".conf", ".conflist", ".json"}, filepath.Ext(entry.Name()))
+	})
+	if !ok {
+		return nil, fmt.Errorf("no CNI config files in %s", confDir)
+	}
+	confBytes, err := os.ReadFile(filepath.Join(confDir, entry.Name()))
+	if err != nil {
+		return nil, err
+	}
+	var conf cniConfig
+	if err := json.Unmarshal(confBytes, &conf); err != nil {
+		return nil, fmt.Errorf("unable to parse CNI config %s: %w", entry.Name(), err)
+	}
+	pluginTypes := lo.Map(conf.Plugins, func(plugin cniConfig, _ int) string { return plugin.Type })
+	return lo.Compact(append([]string{conf.Type}, pluginTypes...)), nil
+}
+
+// DetectCNIProfiles returns the CNIProfiles matching the active CNI config or an error if the CNI has not written a config yet
+func DetectCNIProfiles() ([]*CNIProfile, error) {
+	pluginTypes, err := ParseCNIPluginTypes(CNIConfDir)
+	if err != nil {
+		return nil, err
+	}
+	profiles := lo.Filter(CNIProfiles, func(p *CNIProfile, _ int) bool { return p.Detect(pluginTypes) })
+	if len(profiles) == 0 {
+		log.Printf("no CNI profile matches the %s plugins, no CNI events are registered", strings.Join(pluginTypes, ", "))
+	}
+	return profiles, nil
+}
+
+// GetCNIProfile returns the built-in CNI profile with the name
+func GetCNIProfile(name string) (*CNIProfile, error) {
+	profile, ok := lo.Find(CNIProfiles, func(p *CNIProfile) bool { return strings.EqualFold(p.Name, name) })
+	if !ok {
+		return nil, fmt.Errorf("CNI profile \"%s\" must be one of %s", name, strings.Join(lo.Map(CNIProfiles, func(p *CNIProfile, _ int) string { return p.Name }), ", "))
+	}
+	return profile, nil
+}
+
+// WithCNIProfiles sets the profiles of the node's CNI instead of detecting them from the CNI config
+func (m *Measurer) WithCNIProfiles(profiles ...*CNIProfile) *Measurer {
+	m.cniProfiles = profiles
+	m.cniDetected = true
+	return m
+}
+
+// CNIProfiles returns the configured CNI profiles or detects them from the CNI config, there are none until the CNI has written its config
+func (m *Measurer) CNIProfiles() []*CNIProfile {
+	_ = m.detectCNIProfiles()
+	return m.cniProfiles
+}
+
+// detectCNIProfiles detects the CNI profiles from the CNI config unless they are configured or have already been detected
+func (m *Measurer) detectCNIProfiles() error {
+	if m.cniDetected {
+		return nil
+	}
+	profiles, err := DetectCNIProfiles()
+	if err != nil {
+		return err
+	}
+	m.cniProfiles, m.cniDetected = profiles, true
+	return nil
+}
+
+// registerDetectedCNIProfiles registers the sources, phases, and events of the CNI profiles once the CNI has written its config
+// if no CNI config was found when the default sources were registered, since NLK may start before the CNI.
+func (m *Measurer) registerDetectedCNIProfiles() error {
+	if !m.cniPending || m.detectCNIProfiles() != nil {
+		return nil
+	}
+	m.cniPending = false
+	log.Printf("detected the %s CNI profiles, registering their events", strings.Join(lo.Map(m.cniProfiles, func(p *CNIProfile, _ int) string { return p.Name }), ", "))
+	for _, cniProfile := range m.cniProfiles {
+		m.RegisterSources(cniProfile.Sources(m)...)
+		m.RegisterPhases(cniProfile.Phases...)
+		if _, err := m.RegisterEvents(cniProfile.Events(m)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
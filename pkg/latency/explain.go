@@ -0,0 +1,158 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+const explainTimestampLayout = "2006-01-02T15:04:05Z"
+
+// Explanation describes why events could not be measured
+type Explanation struct {
+	// MissingPrerequisites are clients or data the Measurer requires which are unavailable
+	MissingPrerequisites []string            `json:"missingPrerequisites,omitempty"`
+	MeasuredEvents       int                 `json:"measuredEvents"`
+	Events               []*EventExplanation `json:"events"`
+}
+
+// EventExplanation describes why a single event could not be measured
+type EventExplanation struct {
+	Event  *sources.Event   `json:"event"`
+	Source string           `json:"source"`
+	Errors []ExplainedError `json:"errors,omitempty"`
+	*sources.Explanation
+}
+
+// ExplainedError is an error that occurred while measuring an event along with its category
+type ExplainedError struct {
+	Error    string `json:"error"`
+	Category string `json:"category"`
+}
+
+// Explain describes each registered event without a successful timing in the measurement using the errors
+// that occurred and the diagnostics of sources implementing the sources.Explainer interface
+func (m *Measurer) Explain(ctx context.Context, measurement *Measurement) *Explanation {
+	explanation := &Explanation{Events: []*EventExplanation{}}
+	if m.imdsClient == nil {
+		explanation.MissingPrerequisites = append(explanation.MissingPrerequisites, "EC2 IMDS: the imds client is not configured so EC2 metadata and the EC2 IMDS source are unavailable")
+	}
+	if m.k8sClientset == nil {
+		explanation.MissingPrerequisites = append(explanation.MissingPrerequisites, "K8s: the k8s clientset is not configured so the K8s source is unavailable")
+	} else if _, err := m.discoverNodeName(ctx); err != nil {
+		explanation.MissingPrerequisites = append(explanation.MissingPrerequisites, fmt.Sprintf("node name: %s", err))
+	}
+	for _, event := range m.events {
+		timings := lo.Filter(measurement.Timings, func(t *sources.Timing, _ int) bool { return t.Event.Name == event.Name })
+		if lo.ContainsBy(timings, func(t *sources.Timing) bool { return t.Error == nil }) {
+			explanation.MeasuredEvents++
+			continue
+		}
+		eventExplanation := &EventExplanation{
+			Event:       event,
+			Source:      event.Src.String(),
+			Explanation: &sources.Explanation{},
+		}
+		var err error
+		for _, t := range lo.UniqBy(timings, func(t *sources.Timing) string { return t.Error.Error() }) {
+			eventExplanation.Errors = append(eventExplanation.Errors, ExplainedError{
+				Error:    t.Error.Error(),
+				Category: sources.ErrorCategory(t.Error),
+			})
+			// the first error is explained since it is the earliest and usually the root cause
+			if err == nil {
+				err = t.Error
+			}
+		}
+		if explainer, ok := event.Src.(sources.Explainer); ok {
+			eventExplanation.Explanation = explainer.Explain(event, err)
+		}
+		explanation.Events = append(explanation.Events, eventExplanation)
+	}
+	return explanation
+}
+
+// Write writes a markdown view of the Explanation
+func (e *Explanation) Write(w io.Writer) {
+	fmt.Fprintf(w, "### Unmeasured Events (%d of %d)\n", len(e.Events), len(e.Events)+e.MeasuredEvents)
+	if len(e.MissingPrerequisites) != 0 {
+		fmt.Fprintln(w, "Missing prerequisites:")
+		for _, prerequisite := range e.MissingPrerequisites {
+			fmt.Fprintf(w, "  - %s\n", prerequisite)
+		}
+	}
+	for _, event := range e.Events {
+		fmt.Fprintf(w, "\n#### %s (%s) | %s | %s\n", event.Event.Name, event.Event.Metric, event.Event.SrcName, event.Source)
+		if len(event.Errors) == 0 {
+			fmt.Fprintln(w, "No timing was found: the source returned no results or the event occurred after the last terminal event")
+		}
+		for _, err := range event.Errors {
+			fmt.Fprintf(w, "Error (%s): %s\n", err.Category, err.Error)
+		}
+		if len(event.Files) != 0 {
+			fmt.Fprintln(w, "Files:")
+			for _, file := range event.Files {
+				fmt.Fprintf(w, "  - %s\n", describeFile(file))
+			}
+		}
+		if len(event.NearestMatches) != 0 {
+			fmt.Fprintln(w, "Nearest matches:")
+			for _, match := range event.NearestMatches {
+				fmt.Fprintf(w, "  - %.0f%% %s:%d: %s\n", match.Score*100, match.File, match.LineNumber, match.Line)
+			}
+		}
+		if event.TimestampLine != "" {
+			fmt.Fprintf(w, "Timestamp regex \"%s\" failed on line: %s\n", event.TimestampRegex, event.TimestampLine)
+		}
+		if len(event.MissingPrerequisites) != 0 {
+			fmt.Fprintln(w, "Missing prerequisites:")
+			for _, prerequisite := range event.MissingPrerequisites {
+				fmt.Fprintf(w, "  - %s\n", prerequisite)
+			}
+		}
+	}
+}
+
+// describeFile formats a log file's path, size, and time range
+func describeFile(file sources.LogFile) string {
+	description := fmt.Sprintf("%s%s | %s", file.Path, lo.Ternary(file.Resolved, " (searched)", ""), formatBytes(file.Size))
+	if file.FirstTimestamp != nil && file.LastTimestamp != nil {
+		description += fmt.Sprintf(" | %s - %s", file.FirstTimestamp.Format(explainTimestampLayout), file.LastTimestamp.Format(explainTimestampLayout))
+	}
+	if file.Error != "" {
+		description += fmt.Sprintf(" | %s", file.Error)
+	}
+	return description
+}
+
+// formatBytes formats a size in bytes using binary units
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
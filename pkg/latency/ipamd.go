@@ -0,0 +1,70 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"regexp"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources/ipamd"
+)
+
+var (
+	// IPAMD logs JSON lines with the message in the "msg" field (i.e. {"level":"info","ts":"2024-01-01T00:00:00.000Z","caller":"...","msg":"Starting L-IPAMD v1.18.0  ..."})
+	ipamdStart       = regexp.MustCompile(`.*"msg":"Starting L-IPAMD.*`)
+	ipamdENIAttached = regexp.MustCompile(`.*"msg":"Successfully created and attached a new ENI (eni-[0-9a-f]+) to instance.*`)
+	// the warm pool is ready once the periodic pool stats first report allocated addresses
+	ipamdWarmPoolReady = regexp.MustCompile(`.*"msg":"IP pool stats: (Total IPs/Prefixes = [1-9][0-9]*/[0-9]+, AssignedIPs/CooldownIPs: [0-9]+/[0-9]+).*`)
+	// the plugin logs the ipamd response for every pod sandbox with the assigned address (i.e. Success:true IPv4Addr:\"192.168.1.2\")
+	pluginPodIPAssigned = regexp.MustCompile(`.*"msg":"Received add network response from ipamd for container .*Success:true.*`)
+	pluginPodIP         = regexp.MustCompile(`IPv[46]Addr:\\"([^"\\]+)\\"`)
+)
+
+// ipamdEvents are the IPAMD start, ENI, and warm pool events and the first pod IP assigned by the CNI plugin
+func ipamdEvents() []*sources.Event {
+	return []*sources.Event{
+		{
+			Name:          "IPAMD Start",
+			Metric:        "ipamd_start",
+			SrcName:       ipamd.Name,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(ipamdStart),
+		},
+		{
+			Name:          "IPAMD ENI Attached",
+			Metric:        "ipamd_eni_attached",
+			SrcName:       ipamd.Name,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			CommentFn:     sources.CommentSubmatch(ipamdENIAttached),
+			FindFn:        sources.FindByRegex(ipamdENIAttached),
+		},
+		{
+			Name:          "IPAMD Warm Pool Ready",
+			Metric:        "ipamd_warm_pool_ready",
+			SrcName:       ipamd.Name,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			CommentFn:     sources.CommentSubmatch(ipamdWarmPoolReady),
+			FindFn:        sources.FindByRegex(ipamdWarmPoolReady),
+		},
+		{
+			Name:          "First Pod IP Assigned",
+			Metric:        "first_pod_ip_assigned",
+			SrcName:       ipamd.PluginName,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			CommentFn:     sources.CommentSubmatch(pluginPodIP),
+			FindFn:        sources.FindByRegex(pluginPodIPAssigned),
+		},
+	}
+}
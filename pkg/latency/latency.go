@@ -20,49 +20,86 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
-	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
-	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/olekukonko/tablewriter"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/samber/lo"
 	"go.uber.org/multierr"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
-	"github.com/awslabs/node-latency-for-k8s/pkg/sources/awsnode"
 	ec2src "github.com/awslabs/node-latency-for-k8s/pkg/sources/ec2"
 	imdssrc "github.com/awslabs/node-latency-for-k8s/pkg/sources/imds"
 	k8ssrc "github.com/awslabs/node-latency-for-k8s/pkg/sources/k8s"
-	"github.com/awslabs/node-latency-for-k8s/pkg/sources/messages"
+	karpentersrc "github.com/awslabs/node-latency-for-k8s/pkg/sources/karpenter"
+)
+
+var (
+	// DefaultMetricDimensions are the Metadata dimensions added to metrics when no metric dimensions are configured
+	DefaultMetricDimensions = []string{"instanceType", "amiID", "region", "availabilityZone", "autoScalingGroup", "nodePool", "capacityType"}
+	// OptionalMetricDimensions are the Metadata dimensions that are only added to metrics when configured since they increase cardinality
+	OptionalMetricDimensions = []string{"osImage", "kernelVersion", "kubeletVersion", "containerRuntimeVersion", "cniVersion"}
+	// baseMetricDimensions are added to metrics even when the Metadata does not have a value for them
+	baseMetricDimensions = []string{"instanceType", "amiID", "region", "availabilityZone"}
+	// ReservedDimensions are the dimensions set from the Measurement which extra dimensions are not allowed to override
+	ReservedDimensions = lo.Flatten([][]string{{"experiment", "source", "operation", "stage", "module"}, DefaultMetricDimensions, OptionalMetricDimensions})
 )
 
 // Measurer holds registered sources and events to use for timing runs
 type Measurer struct {
-	sources      map[string]sources.Source
-	events       []*sources.Event
-	metadata     *Metadata
-	imdsClient   *imds.Client
-	ec2Client    *ec2.Client
-	k8sClientset *kubernetes.Clientset
-	podNamespace string
-	nodeName     string
+	sources           map[string]sources.Source
+	events            []*sources.Event
+	phases            []*Phase
+	metadata          *Metadata
+	imdsClient        *imds.Client
+	ec2Client         *ec2.Client
+	asgClient         *autoscaling.Client
+	k8sClientset      *kubernetes.Clientset
+	dynamicClient     dynamic.Interface
+	podNamespace      string
+	nodeName          string
+	currentBootOnly   bool
+	bootSelector      *BootSelector
+	sourceTimeout     time.Duration
+	sourceTimeouts    map[string]time.Duration
+	imdsDimensions    map[string]string
+	labelDimensions   map[string]string
+	annotDimensions   map[string]string
+	dimensions        map[string]string
+	cache             resultCache
+	apiCalls          sources.APICalls
+	k8sAPICalls       sources.APICalls
+	metadataProviders []MetadataProvider
+	metricDimensions  []string
+	profile           *Profile
+	cniProfiles       []*CNIProfile
+	cniDetected       bool
+	metadataRefresh   metadataRefresh
+	sourceMetadata    sourceMetadata
+	// cniPending is set if no CNI config was found when the default sources were registered, the CNI profiles are then registered once it is
+	cniPending bool
 }
 
 // Measurement is a specific timing produced from a Measurer run
 type Measurement struct {
 	Metadata *Metadata         `json:"metadata"`
+	Boot     *sources.Boot     `json:"boot,omitempty"`
 	Timings  []*sources.Timing `json:"timings"`
+	// APICalls are the number of API calls made by each source keyed by source name and operation
+	APICalls       map[string]map[string]int64 `json:"apiCalls,omitempty"`
+	phases         []*Phase
+	dimensionNames []string
 }
 
 // Metadata provides data about the node where measurements are executed
@@ -75,11 +112,33 @@ type Metadata struct {
 	AvailabilityZone string `json:"availabilityZone"`
 	PrivateIP        string `json:"privateIP"`
 	AMIID            string `json:"amiID"`
+	// AutoScalingGroup is the Auto Scaling group that launched the node, if any
+	AutoScalingGroup string `json:"autoScalingGroup,omitempty"`
+	// TargetLifecycleState is the Auto Scaling target lifecycle state (i.e. InService) when the node was measured, if any
+	TargetLifecycleState string `json:"targetLifecycleState,omitempty"`
+	// NodePool is the Karpenter NodePool that launched the node, if any
+	NodePool string `json:"nodePool,omitempty"`
+	// CapacityType is the Karpenter capacity type (i.e. spot or on-demand) of the node, if any
+	CapacityType   string `json:"capacityType,omitempty"`
+	OSImage        string `json:"osImage,omitempty"`
+	KernelVersion  string `json:"kernelVersion,omitempty"`
+	KubeletVersion string `json:"kubeletVersion,omitempty"`
+	// ContainerRuntimeVersion is prefixed with the runtime name (i.e. containerd://1.6.6)
+	ContainerRuntimeVersion string `json:"containerRuntimeVersion,omitempty"`
+	// CNIVersion is the version of the VPC CNI (aws-node), if any
+	CNIVersion string `json:"cniVersion,omitempty"`
+	// Extra are additional metric dimensions
+	Extra map[string]string `json:"extra,omitempty"`
+	// nodeLabels and nodeAnnotations of the K8s Node are used to map labels and annotations into Extra
+	nodeLabels      map[string]string
+	nodeAnnotations map[string]string
 }
 
 // ChartOptions allows configuration of the markdown chart
 type ChartOptions struct {
 	HiddenColumns []string
+	// ShowBoot prints the boot the Measurement is scoped to
+	ShowBoot bool
 }
 
 // Chart column label consts
@@ -90,15 +149,21 @@ const (
 	ChartColumnComment   = "Comment"
 )
 
+// apiCallsMetric is the prometheus counter of API calls made by sources
+const apiCallsMetric = "api_calls_total"
+
+// DefaultSourceTimeout is the default time each source is given to find all of its events in a single timing run
+const DefaultSourceTimeout = 30 * time.Second
+
 // Default Event regular expressions
 var (
-	vmInit                = regexp.MustCompile(`.*kernel: Linux version.*`)
+	vmInit                = regexp.MustCompile(`.*kernel: (\[ *[0-9.]+\] )?Linux version.*`)
 	networkStart          = regexp.MustCompile(`.*Reached target Network \(Pre\).*`)
 	networkReady          = regexp.MustCompile(`.*Reached target Network\..*`)
-	cloudInitInitialStart = regexp.MustCompile(`.*cloud-init: Cloud-init v.* running 'init'.*`)
-	cloudInitConfigStart  = regexp.MustCompile(`.*cloud-init: Cloud-init v.* running 'modules:config'.*`)
-	cloudInitFinalStart   = regexp.MustCompile(`.*cloud-init: Cloud-init v.* running 'modules:final'.*`)
-	cloudInitFinalFinish  = regexp.MustCompile(`.*cloud-init: Cloud-init v.* finished`)
+	cloudInitInitialStart = regexp.MustCompile(`.*cloud-init(\[[0-9]+\])?: Cloud-init v.* running 'init'.*`)
+	cloudInitConfigStart  = regexp.MustCompile(`.*cloud-init(\[[0-9]+\])?: Cloud-init v.* running 'modules:config'.*`)
+	cloudInitFinalStart   = regexp.MustCompile(`.*cloud-init(\[[0-9]+\])?: Cloud-init v.* running 'modules:final'.*`)
+	cloudInitFinalFinish  = regexp.MustCompile(`.*cloud-init(\[[0-9]+\])?: Cloud-init v.* finished`)
 	containerdStart       = regexp.MustCompile(`.*Starting containerd container runtime.*`)
 	containerdInitialized = regexp.MustCompile(`.*Started containerd container runtime.*`)
 	kubeletStart          = regexp.MustCompile(`.*Starting Kubernetes Kubelet.*`)
@@ -116,7 +181,8 @@ var (
 // New creates a new instance of a Measurer
 func New() *Measurer {
 	return &Measurer{
-		sources: make(map[string]sources.Source),
+		sources:       make(map[string]sources.Source),
+		sourceTimeout: DefaultSourceTimeout,
 	}
 }
 
@@ -132,12 +198,54 @@ func (m *Measurer) WithEC2Client(ec2Client *ec2.Client) *Measurer {
 	return m
 }
 
+// WithAutoScalingClient is a builder func that adds an autoscaling client to a Measurer which is used to find the launch of instances in an ASG
+func (m *Measurer) WithAutoScalingClient(asgClient *autoscaling.Client) *Measurer {
+	m.asgClient = asgClient
+	return m
+}
+
+// WithIMDSDimensions maps extra EC2 IMDS metadata paths (i.e. instance-life-cycle) into the Metadata and metric dimensions keyed by dimension name
+func (m *Measurer) WithIMDSDimensions(imdsDimensions map[string]string) *Measurer {
+	m.imdsDimensions = imdsDimensions
+	return m
+}
+
+// WithNodeLabelDimensions maps K8s Node labels into the Metadata and metric dimensions keyed by dimension name
+func (m *Measurer) WithNodeLabelDimensions(labelDimensions map[string]string) *Measurer {
+	m.labelDimensions = labelDimensions
+	return m
+}
+
+// WithNodeAnnotationDimensions maps K8s Node annotations into the Metadata and metric dimensions keyed by dimension name
+func (m *Measurer) WithNodeAnnotationDimensions(annotationDimensions map[string]string) *Measurer {
+	m.annotDimensions = annotationDimensions
+	return m
+}
+
+// WithDimensions adds static dimensions (i.e. team or test-run-id) to the Metadata and metric dimensions
+func (m *Measurer) WithDimensions(dimensions map[string]string) *Measurer {
+	m.dimensions = dimensions
+	return m
+}
+
+// WithMetricDimensions sets the Metadata dimensions added to metrics from DefaultMetricDimensions and OptionalMetricDimensions
+func (m *Measurer) WithMetricDimensions(dimensions []string) *Measurer {
+	m.metricDimensions = dimensions
+	return m
+}
+
 // WithK8sClientset is a builder func that adds a k8s clientset to a Measurer
 func (m *Measurer) WithK8sClientset(clientset *kubernetes.Clientset) *Measurer {
 	m.k8sClientset = clientset
 	return m
 }
 
+// WithK8sDynamicClient is a builder func that adds a k8s dynamic client to a Measurer which is used to read Karpenter NodeClaims
+func (m *Measurer) WithK8sDynamicClient(dynamicClient dynamic.Interface) *Measurer {
+	m.dynamicClient = dynamicClient
+	return m
+}
+
 // WithPodNamespace sets the pod namespace that will be queried to measure pod creation to running time
 func (m *Measurer) WithPodNamespace(podNamespace string) *Measurer {
 	m.podNamespace = podNamespace
@@ -150,6 +258,24 @@ func (m *Measurer) WithNodeName(nodeName string) *Measurer {
 	return m
 }
 
+// WithCurrentBootOnly restricts the default log sources to log files that have been modified since the current boot
+func (m *Measurer) WithCurrentBootOnly(currentBootOnly bool) *Measurer {
+	m.currentBootOnly = currentBootOnly
+	return m
+}
+
+// WithSourceTimeout sets the time each source is given to find all of its events in a single timing run, 0 disables the timeout
+func (m *Measurer) WithSourceTimeout(timeout time.Duration) *Measurer {
+	m.sourceTimeout = timeout
+	return m
+}
+
+// WithSourceTimeouts overrides the source timeout for specific sources by source name
+func (m *Measurer) WithSourceTimeouts(timeouts map[string]time.Duration) *Measurer {
+	m.sourceTimeouts = timeouts
+	return m
+}
+
 // MustWithDefaultConfig registers the default sources and events to the Measurer and panics if any errors occur
 func (m *Measurer) MustWithDefaultConfig() *Measurer {
 	return lo.Must(m.RegisterDefaultSources().RegisterDefaultEvents())
@@ -184,63 +310,169 @@ func (m *Measurer) GetSource(name string) (sources.Source, bool) {
 	return src, ok
 }
 
-// Measure executes a single timing run with the registered sources and events
+// Measure executes a single timing run with the registered sources and events, scoped to the selected boot if a boot selector is configured
 func (m *Measurer) Measure(ctx context.Context) *Measurement {
-	var timings []*sources.Timing
-	for _, event := range m.events {
-		results, err := event.Src.Find(event)
-		if len(results) == 0 {
-			results = []sources.FindResult{}
-		}
-		for _, result := range results {
-			timings = append(timings, &sources.Timing{
-				Event:     event,
-				Timestamp: result.Timestamp,
-				Comment:   result.Comment,
-				Error:     multierr.Append(err, result.Err),
-			})
-		}
+	boot, err := m.selectBoot()
+	return m.measure(ctx, boot, err)
+}
+
+// measure executes a single timing run scoped to the boot. If the boot could not be selected, bootErr is recorded for boot aware events.
+// Sources are evaluated concurrently, each within its own timeout, while the events of a source are evaluated serially since sources cache their data.
+func (m *Measurer) measure(ctx context.Context, boot *sources.Boot, bootErr error) *Measurement {
+	if err := m.registerDetectedCNIProfiles(); err != nil {
+		log.Printf("unable to register the CNI events: %v", err)
 	}
+	m.scopeToBoot(boot)
+	eventTimings := make([][]*sources.Timing, len(m.events))
+	eventIndexesBySource := lo.GroupBy(lo.Range(len(m.events)), func(i int) string { return m.events[i].SrcName })
+	var wg sync.WaitGroup
+	for srcName, eventIndexes := range eventIndexesBySource {
+		wg.Add(1)
+		go func(srcName string, eventIndexes []int) {
+			defer wg.Done()
+			events := lo.Map(eventIndexes, func(i int, _ int) *sources.Event { return m.events[i] })
+			for j, timings := range m.measureSource(ctx, srcName, events, boot, bootErr) {
+				eventTimings[eventIndexes[j]] = timings
+			}
+		}(srcName, eventIndexes)
+	}
+	wg.Wait()
+	timings := lo.Flatten(eventTimings)
 	// Sort timings so they are in chronological order
 	sort.Slice(timings, func(i, j int) bool {
 		return timings[i].Timestamp.UnixMicro() < timings[j].Timestamp.UnixMicro()
 	})
 
-	// Find the last terminal event index to filter out everything past
+	// Find the last successful terminal event index to filter out everything past
 	if _, lastTerminalIndex, ok := lo.FindLastIndexOf(timings, func(t *sources.Timing) bool {
-		return t.Event.Terminal
+		return t.Event.Terminal && t.Error == nil
 	}); ok {
 		timings = timings[:lastTerminalIndex+1]
 	}
-	firstSuccessfulTiming := timings[0]
-	// Find first successful timing
-	for _, t := range timings {
-		if t.Error == nil {
-			firstSuccessfulTiming = t
-			break
+	// Find first successful timing and add the normalized time delta
+	if firstSuccessfulTiming, ok := lo.Find(timings, func(t *sources.Timing) bool { return t.Error == nil }); ok {
+		for _, t := range timings {
+			if t.Error == nil {
+				t.T = t.Timestamp.Sub(firstSuccessfulTiming.Timestamp)
+			}
 		}
 	}
-	// Add normalized time delta
-	for _, t := range timings {
-		t.T = t.Timestamp.Sub(firstSuccessfulTiming.Timestamp)
-	}
 	// ignore metadata errors
 	metadata, _ := m.getMetadata(ctx)
+	m.addSourceMetadata(ctx, metadata)
 	return &Measurement{
-		Metadata: metadata,
-		Timings:  timings,
+		Metadata:       metadata,
+		Boot:           boot,
+		Timings:        timings,
+		APICalls:       m.APICalls(),
+		phases:         m.phases,
+		dimensionNames: m.metricDimensions,
 	}
 }
 
-// MeasureUntil executes timing runs with the registered sources and events until all terminal events have timings or the timeout is reached
+// measureSource finds the timings of a source's events within the source's timeout. Timings of the current boot are cached across
+// runs so that resolved events are not queried again and a source is not queried while it is backing off from failed API calls.
+func (m *Measurer) measureSource(ctx context.Context, srcName string, events []*sources.Event, boot *sources.Boot, bootErr error) [][]*sources.Timing {
+	srcCtx, cancel := m.sourceContext(ctx, srcName)
+	defer cancel()
+	eventTimings := make([][]*sources.Timing, len(events))
+	if boot != nil && !boot.Current {
+		for i, event := range events {
+			eventTimings[i] = m.measureEvent(srcCtx, event, boot, bootErr)
+		}
+		return eventTimings
+	}
+	if m.cache.backingOff(srcName, time.Now()) {
+		for i, event := range events {
+			eventTimings[i] = m.cache.lastTimings(event)
+		}
+		return eventTimings
+	}
+	for i, event := range events {
+		if timings, ok := m.cache.resolvedTimings(event); ok {
+			eventTimings[i] = timings
+			continue
+		}
+		eventTimings[i] = m.measureEvent(srcCtx, event, boot, bootErr)
+	}
+	m.cache.record(srcName, events, eventTimings, time.Now())
+	return eventTimings
+}
+
+// measureEvent finds the timings of a single event
+func (m *Measurer) measureEvent(ctx context.Context, event *sources.Event, boot *sources.Boot, bootErr error) []*sources.Timing {
+	_, bootAware := event.Src.(sources.BootScoper)
+	// sources that are not boot aware describe the current instance launch
+	if boot != nil && !boot.Current && !bootAware {
+		return nil
+	}
+	var results []sources.FindResult
+	var err error
+	if bootErr != nil && bootAware {
+		err = sources.Errorf(sources.ErrorCategoryNoMatch, "unable to select boot: %w", bootErr)
+	} else {
+		results, err = event.Src.Find(ctx, event)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = sources.Errorf(sources.ErrorCategoryTimeout, "source \"%s\" timed out: %w", event.SrcName, err)
+	}
+	if len(results) == 0 {
+		// record an empty result so that the error is reported with the event
+		results = []sources.FindResult{{}}
+	}
+	var timings []*sources.Timing
+	for _, result := range results {
+		if err == nil && result.Err == nil && result.Timestamp.IsZero() {
+			continue
+		}
+		timings = append(timings, &sources.Timing{
+			Event:     event,
+			Timestamp: result.Timestamp,
+			Comment:   result.Comment,
+			Error:     multierr.Append(err, result.Err),
+			Provenance: &sources.Provenance{
+				Source:     event.SrcName,
+				File:       result.File,
+				Offset:     result.Offset,
+				LineNumber: result.LineNumber,
+				Line:       result.Line,
+			},
+		})
+	}
+	return timings
+}
+
+// sourceContext returns a context with the source's timeout
+func (m *Measurer) sourceContext(ctx context.Context, srcName string) (context.Context, context.CancelFunc) {
+	timeout, ok := m.sourceTimeouts[srcName]
+	if !ok {
+		timeout = m.sourceTimeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// MeasureUntil executes timing runs with the registered sources and events until all terminal events have timings or the timeout is reached.
+// The boot is selected once so that retries do not rescan the logs for boots.
 func (m *Measurer) MeasureUntil(ctx context.Context, timeout time.Duration, retryDelay time.Duration) (*Measurement, error) {
+	boot, bootErr := m.selectBoot()
+	return m.measureUntil(ctx, timeout, retryDelay, func(ctx context.Context) *Measurement { return m.measure(ctx, boot, bootErr) })
+}
+
+// measureUntil executes the measure func until all terminal events have timings or the timeout is reached
+// The context passed to the measure func expires at the timeout so that slow sources cannot extend the run past the timeout.
+func (m *Measurer) measureUntil(ctx context.Context, timeout time.Duration, retryDelay time.Duration, measure func(ctx context.Context) *Measurement) (*Measurement, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 	startTime := time.Now().UTC()
 	var measurement *Measurement
 	terminalEvents := lo.CountBy(m.events, func(e *sources.Event) bool { return e.Terminal })
 	done := false
 	for !done && time.Since(startTime) < timeout {
 		done = false
-		measurement = m.Measure(ctx)
+		measurement = measure(ctx)
 		for _, m := range measurement.Timings {
 			if m.Error != nil {
 				log.Printf("Unable to retrieve timing for Event \"%s\": %v\n", m.Event.Name, m.Error)
@@ -262,44 +494,132 @@ func (m *Measurer) MeasureUntil(ctx context.Context, timeout time.Duration, retr
 		for _, s := range m.sources {
 			s.ClearCache()
 		}
-		time.Sleep(retryDelay)
+		select {
+		case <-ctx.Done():
+		case <-time.After(retryDelay):
+		}
 	}
 	if terminalEvents > 0 {
 		unmeasuredTerminalEvents := lo.Filter(m.events, func(e *sources.Event, _ int) bool {
-			return e.Terminal && lo.CountBy(measurement.Timings, func(t *sources.Timing) bool { return t.Event.Name == e.Name }) == 0
+			return e.Terminal && lo.CountBy(measurement.Timings, func(t *sources.Timing) bool { return t.Event.Name == e.Name && t.Error == nil }) == 0
 		})
 		unmeasuredTerminalEventNames := lo.Map(unmeasuredTerminalEvents, func(e *sources.Event, _ int) string { return e.Name })
 		return measurement, fmt.Errorf("unable to measure terminal events: %v", unmeasuredTerminalEventNames)
 	}
 	unmeasuredEvents := lo.Filter(m.events, func(e *sources.Event, _ int) bool {
-		return lo.CountBy(measurement.Timings, func(t *sources.Timing) bool { return t.Event.Name == e.Name }) == 0
+		return lo.CountBy(measurement.Timings, func(t *sources.Timing) bool { return t.Event.Name == e.Name && t.Error == nil }) == 0
 	})
 	unmeasuredEventNames := lo.Map(unmeasuredEvents, func(e *sources.Event, _ int) string { return e.Name })
 	return measurement, fmt.Errorf("unable to measure events %v within timeout window", unmeasuredEventNames)
 }
 
-// getMetadata populates the metadata for a Measurement
-func (m *Measurer) getMetadata(ctx context.Context) (*Metadata, error) {
-	if m.metadata != nil {
-		return m.metadata, nil
+// getIMDSDimensions retrieves the EC2 IMDS metadata paths mapped to dimensions, paths which cannot be retrieved are omitted
+func (m *Measurer) getIMDSDimensions(ctx context.Context) map[string]string {
+	if len(m.imdsDimensions) == 0 {
+		return nil
 	}
-	if m.imdsClient == nil {
-		return nil, errors.New("imds client is nil")
+	dimensions := map[string]string{}
+	for dimension, path := range m.imdsDimensions {
+		m.apiCalls.Inc("GetMetadata")
+		value, err := imdssrc.GetMetadata(ctx, m.imdsClient, path)
+		if err != nil {
+			log.Printf("unable to retrieve EC2 IMDS dimension %s: %v", dimension, err)
+			continue
+		}
+		dimensions[dimension] = strings.TrimSpace(value)
 	}
-	idDoc, err := m.imdsClient.GetInstanceIdentityDocument(ctx, &imds.GetInstanceIdentityDocumentInput{})
-	if err != nil {
-		return nil, fmt.Errorf("unable to retrieve instance-identity document: %w", err)
+	return dimensions
+}
+
+// APICalls returns the number of API calls made by each source keyed by source name and operation,
+// calls the Measurer makes to EC2 IMDS and K8s for metadata are counted with the EC2 IMDS and K8s sources
+func (m *Measurer) APICalls() map[string]map[string]int64 {
+	apiCalls := map[string]map[string]int64{}
+	for name, src := range m.sources {
+		if counter, ok := src.(sources.APICallCounter); ok && len(counter.APICalls()) != 0 {
+			apiCalls[name] = counter.APICalls()
+		}
+	}
+	for name, measurerCalls := range map[string]map[string]int64{imdssrc.Name: m.apiCalls.Counts(), k8ssrc.Name: m.k8sAPICalls.Counts()} {
+		if len(measurerCalls) == 0 {
+			continue
+		}
+		srcCalls := lo.Assign(apiCalls[name])
+		for operation, count := range measurerCalls {
+			srcCalls[operation] += count
+		}
+		apiCalls[name] = srcCalls
+	}
+	return apiCalls
+}
+
+// sourceMetadata is the metadata resolved from the EC2, EC2 IMDS, and Karpenter sources, which does not change across timing runs
+type sourceMetadata struct {
+	autoScalingGroup     string
+	targetLifecycleState string
+	nodePool             string
+	capacityType         string
+	// resolved are the sources whose metadata has been retrieved, the others are retried with the same backoff as failing sources
+	resolved map[string]bool
+	backoffs map[string]*sourceBackoff
+}
+
+// addSourceMetadata adds the metadata of the registered EC2, EC2 IMDS, and Karpenter sources, which is retrieved once per source
+func (m *Measurer) addSourceMetadata(ctx context.Context, metadata *Metadata) {
+	if metadata == nil {
+		return
+	}
+	sm := &m.sourceMetadata
+	if sm.resolved == nil {
+		sm.resolved = map[string]bool{}
+		sm.backoffs = map[string]*sourceBackoff{}
+	}
+	for _, resolver := range []struct {
+		srcName string
+		resolve func(ctx context.Context, src sources.Source) error
+	}{
+		{srcName: ec2src.Name, resolve: m.resolveEC2Metadata},
+		{srcName: imdssrc.Name, resolve: m.resolveIMDSMetadata},
+		{srcName: karpentersrc.Name, resolve: m.resolveKarpenterMetadata},
+	} {
+		src, ok := m.GetSource(resolver.srcName)
+		if !ok || sm.resolved[resolver.srcName] {
+			continue
+		}
+		backoff := lo.ValueOr(sm.backoffs, resolver.srcName, &sourceBackoff{})
+		if time.Now().Before(backoff.retryAt) {
+			continue
+		}
+		if err := resolver.resolve(ctx, src); err != nil {
+			backoff.failures++
+			backoff.retryAt = backoffRetryAt(time.Now(), backoff.failures)
+			sm.backoffs[resolver.srcName] = backoff
+			continue
+		}
+		sm.resolved[resolver.srcName] = true
 	}
-	return &Metadata{
-		Region:           idDoc.Region,
-		InstanceType:     idDoc.InstanceType,
-		InstanceID:       idDoc.InstanceID,
-		AccountID:        idDoc.AccountID,
-		Architecture:     idDoc.Architecture,
-		AvailabilityZone: idDoc.AvailabilityZone,
-		AMIID:            idDoc.ImageID,
-		PrivateIP:        idDoc.PrivateIP,
-	}, nil
+	metadata.AutoScalingGroup = sm.autoScalingGroup
+	metadata.TargetLifecycleState = sm.targetLifecycleState
+	metadata.NodePool = sm.nodePool
+	metadata.CapacityType = sm.capacityType
+}
+
+// resolveEC2Metadata retrieves the Auto Scaling group that launched the node
+func (m *Measurer) resolveEC2Metadata(ctx context.Context, src sources.Source) (err error) {
+	m.sourceMetadata.autoScalingGroup, err = src.(*ec2src.Source).AutoScalingGroupName(ctx)
+	return err
+}
+
+// resolveIMDSMetadata retrieves the Auto Scaling target lifecycle state of the node, which is empty if the node is not in an Auto Scaling group
+func (m *Measurer) resolveIMDSMetadata(ctx context.Context, src sources.Source) (err error) {
+	m.sourceMetadata.targetLifecycleState, err = src.(*imdssrc.Source).TargetLifecycleState(ctx)
+	return err
+}
+
+// resolveKarpenterMetadata retrieves the NodePool and capacity type of the node's NodeClaim
+func (m *Measurer) resolveKarpenterMetadata(ctx context.Context, src sources.Source) (err error) {
+	m.sourceMetadata.nodePool, m.sourceMetadata.capacityType, err = src.(*karpentersrc.Source).NodeClaimLabels(ctx)
+	return err
 }
 
 // Chart generates a markdown chart view of a Measurement
@@ -309,6 +629,10 @@ func (m *Measurement) Chart(opts ChartOptions) {
 			m.Metadata.InstanceID, m.Metadata.PrivateIP, m.Metadata.InstanceType, m.Metadata.Architecture,
 			m.Metadata.AvailabilityZone, m.Metadata.AMIID)
 	}
+	if opts.ShowBoot && m.Boot != nil {
+		fmt.Printf("Boot %d%s started at %s%s\n", m.Boot.Index, lo.Ternary(m.Boot.ID != "", " ("+m.Boot.ID+")", ""),
+			m.Boot.Start.Format("2006-01-02T15:04:05Z"), lo.Ternary(m.Boot.Current, " (current)", ""))
+	}
 	table := tablewriter.NewWriter(os.Stdout)
 	headers := []string{ChartColumnEvent, ChartColumnTimestamp, ChartColumnT, ChartColumnComment}
 	table.SetHeader(filterColumns(opts.HiddenColumns, headers, headers))
@@ -361,50 +685,39 @@ func (m *Measurement) RegisterMetrics(register prometheus.Registerer, experiment
 	labels := lo.Keys(dimensions)
 
 	metricCollectors := map[string]*prometheus.GaugeVec{}
-	for _, timing := range lo.UniqBy(m.Timings, func(t *sources.Timing) string { return t.Event.Metric }) {
+	values := m.metricValues()
+	for _, value := range lo.UniqBy(values, func(v metricValue) string { return v.name }) {
 		collector := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-			Name: timing.Event.Metric,
-		}, labels)
+			Name: value.name,
+		}, append(lo.Keys(value.dimensions), labels...))
 		if err := register.Register(collector); err != nil {
-			log.Printf("error registering metric %s: %v", timing.Event.Metric, err)
+			log.Printf("error registering metric %s: %v", value.name, err)
 		}
-		metricCollectors[timing.Event.Metric] = collector
+		metricCollectors[value.name] = collector
 	}
-	for _, timing := range m.Timings {
-		collector, ok := metricCollectors[timing.Event.Metric]
+	for _, value := range values {
+		collector, ok := metricCollectors[value.name]
 		if !ok {
-			log.Printf("error emitting metric for %s", timing.Event.Metric)
+			log.Printf("error emitting metric for %s", value.name)
 			continue
 		}
-		collector.With(dimensions).Set(timing.T.Seconds())
+		collector.With(lo.Assign(dimensions, value.dimensions)).Set(value.seconds)
 	}
-}
-
-// EmitCloudWatchMetrics posts metric data to CloudWatch based on a Measurement
-func (m *Measurement) EmitCloudWatchMetrics(ctx context.Context, cw *cloudwatch.Client, experimentDimension string) error {
-	var errs error
-	dimensions := m.metricDimensions(experimentDimension)
-	for _, timing := range m.Timings {
-		if _, err := cw.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
-			Namespace: aws.String("KubernetesNodeLatency"),
-			MetricData: []types.MetricDatum{
-				{
-					MetricName: aws.String(timing.Event.Metric),
-					Value:      aws.Float64(timing.T.Seconds()),
-					Unit:       types.StandardUnitSeconds,
-					Dimensions: lo.MapToSlice(dimensions, func(k, v string) types.Dimension {
-						return types.Dimension{
-							Name:  aws.String(k),
-							Value: aws.String(v),
-						}
-					}),
-				},
-			},
-		}); err != nil {
-			errs = multierr.Append(errs, err)
+	if len(m.APICalls) == 0 {
+		return
+	}
+	apiCallsCollector := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: apiCallsMetric,
+		Help: "Number of API calls made by a source to measure the node",
+	}, append(labels, "source", "operation"))
+	if err := register.Register(apiCallsCollector); err != nil {
+		log.Printf("error registering metric %s: %v", apiCallsMetric, err)
+	}
+	for source, operations := range m.APICalls {
+		for operation, count := range operations {
+			apiCallsCollector.With(lo.Assign(dimensions, map[string]string{"source": source, "operation": operation})).Add(float64(count))
 		}
 	}
-	return errs
 }
 
 // metricDimensions is a helper to construct default metric dimensions for both cloudwatch and prometheus
@@ -412,59 +725,143 @@ func (m *Measurement) metricDimensions(experimentDimension string) map[string]st
 	dimensions := map[string]string{
 		"experiment": experimentDimension,
 	}
-	if m.Metadata != nil {
-		dimensions = lo.Assign(dimensions, map[string]string{
-			"instanceType":     m.Metadata.InstanceType,
-			"amiID":            m.Metadata.AMIID,
-			"region":           m.Metadata.Region,
-			"availabilityZone": m.Metadata.AvailabilityZone,
-		})
+	if m.Metadata == nil {
+		return dimensions
+	}
+	dimensions = lo.Assign(dimensions, m.Metadata.Extra)
+	values := m.Metadata.dimensionValues()
+	for _, name := range lo.Ternary(m.dimensionNames == nil, DefaultMetricDimensions, m.dimensionNames) {
+		// dimensions other than the base dimensions are omitted when the node does not have them
+		if values[name] == "" && !lo.Contains(baseMetricDimensions, name) {
+			continue
+		}
+		dimensions[name] = values[name]
 	}
 	return dimensions
 }
 
-// RegisterDefaultSources registers the default sources to the Measurer
+// dimensionValues returns the Metadata values that can be used as metric dimensions keyed by dimension name
+func (md *Metadata) dimensionValues() map[string]string {
+	return map[string]string{
+		"instanceType":            md.InstanceType,
+		"amiID":                   md.AMIID,
+		"region":                  md.Region,
+		"availabilityZone":        md.AvailabilityZone,
+		"autoScalingGroup":        md.AutoScalingGroup,
+		"nodePool":                md.NodePool,
+		"capacityType":            md.CapacityType,
+		"osImage":                 md.OSImage,
+		"kernelVersion":           md.KernelVersion,
+		"kubeletVersion":          md.KubeletVersion,
+		"containerRuntimeVersion": md.ContainerRuntimeVersion,
+		"cniVersion":              md.CNIVersion,
+	}
+}
+
+// RegisterDefaultSources registers the default sources of the node OS profile, the CNI profiles, and the APIs to the Measurer
 func (m *Measurer) RegisterDefaultSources() *Measurer {
-	m.RegisterSources([]sources.Source{
-		messages.New(messages.DefaultPath),
-		awsnode.New(awsnode.DefaultPath),
-	}...)
+	m.RegisterSources(m.Profile().Sources(m)...)
+	if err := m.detectCNIProfiles(); err != nil {
+		log.Printf("unable to detect the CNI, the CNI events are registered once the CNI has written its config: %v", err)
+		m.cniPending = true
+	}
+	for _, cniProfile := range m.cniProfiles {
+		m.RegisterSources(cniProfile.Sources(m)...)
+	}
 	if m.imdsClient != nil {
 		m.RegisterSources(imdssrc.New(m.imdsClient))
 	}
 	if m.ec2Client != nil {
 		instanceID := ""
-		if m.imdsClient != nil {
-			md, err := m.getMetadata(context.TODO())
-			if err != nil {
-				log.Printf("unable to retrieve instance-id to register the ec2 event source: %s", err)
-			} else {
-				instanceID = md.InstanceID
-			}
+		md, err := m.getMetadata(context.TODO())
+		if err != nil {
+			log.Printf("unable to retrieve instance-id to register the ec2 event source: %s", err)
+		} else {
+			instanceID = md.InstanceID
 		}
-		m.RegisterSources(ec2src.New(m.ec2Client, instanceID, m.nodeName))
+		m.RegisterSources(ec2src.New(m.ec2Client, m.asgClient, instanceID, m.nodeName))
 	}
 	if m.k8sClientset != nil && m.podNamespace != "" {
-		if m.nodeName == "" && m.imdsClient != nil {
-			out, err := m.imdsClient.GetMetadata(context.TODO(), &imds.GetMetadataInput{Path: "/hostname"})
-			if err != nil {
-				log.Printf("unable to register K8s source because node name is required and is unable to be retrieved via EC2 IMDS: %v\n", err)
-			}
-			dnsName, err := io.ReadAll(out.Content)
-			if err != nil {
-				log.Printf("unable to register K8s source because node name is required and is unable to be read via EC2 IMDS: %v\n", err)
-			}
-			m.nodeName = string(dnsName)
+		if _, err := m.discoverNodeName(context.TODO()); err != nil {
+			log.Printf("unable to register K8s source because node name is required: %v\n", err)
 		}
 		if m.nodeName != "" {
 			m.RegisterSources(k8ssrc.New(m.k8sClientset, m.nodeName, m.podNamespace))
 		}
 	}
+	if m.k8sClientset != nil && m.dynamicClient != nil {
+		resource, err := karpentersrc.DiscoverNodeClaimResource(m.k8sClientset.Discovery())
+		if err != nil {
+			log.Printf("Karpenter source is not registered: %v\n", err)
+		} else if _, err := m.discoverNodeName(context.TODO()); err != nil {
+			log.Printf("unable to register Karpenter source because node name is required: %v\n", err)
+		} else {
+			m.RegisterSources(karpentersrc.New(m.dynamicClient, resource, m.nodeName))
+		}
+	}
 	return m
 }
 
-// RegisterDefaultEvents registers all default events shipped
+// discoverNodeName returns the configured node name or retrieves it from the EC2 IMDS hostname and caches it on the Measurer
+func (m *Measurer) discoverNodeName(ctx context.Context) (string, error) {
+	if m.nodeName != "" {
+		return m.nodeName, nil
+	}
+	if m.imdsClient == nil {
+		return "", errors.New("node name was not provided and imds client is nil")
+	}
+	m.apiCalls.Inc("GetMetadata")
+	dnsName, err := imdssrc.GetMetadata(ctx, m.imdsClient, "hostname")
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve node name via EC2 IMDS: %w", err)
+	}
+	m.nodeName = dnsName
+	return m.nodeName, nil
+}
+
+// RegisterDefaultEvents registers all default events shipped along with the phases derived from them.
+// Events of the EC2, EC2 IMDS, K8s, and Karpenter sources are only registered if the source is registered.
 func (m *Measurer) RegisterDefaultEvents() (*Measurer, error) {
+	profile := m.Profile()
+	m.RegisterPhases(DefaultPhases...)
+	m.RegisterPhases(profile.Phases...)
+	// the CNI profile phases and events are registered once the CNI config is found if it was not found when the sources were registered
+	var cniProfiles []*CNIProfile
+	if !m.cniPending {
+		cniProfiles = m.CNIProfiles()
+	}
+	for _, cniProfile := range cniProfiles {
+		m.RegisterPhases(cniProfile.Phases...)
+	}
+	for _, register := range []struct {
+		srcName  string
+		register func() (*Measurer, error)
+	}{
+		{srcName: k8ssrc.Name, register: m.registerK8sEvents},
+		{srcName: ec2src.Name, register: m.registerEC2Events},
+		{srcName: imdssrc.Name, register: m.registerIMDSEvents},
+		{srcName: karpentersrc.Name, register: m.registerKarpenterEvents},
+	} {
+		if _, ok := m.GetSource(register.srcName); !ok {
+			continue
+		}
+		if _, err := register.register(); err != nil {
+			return m, err
+		}
+	}
+	if _, err := m.RegisterEvents(profile.Events(m)...); err != nil {
+		return m, err
+	}
+	for _, cniProfile := range cniProfiles {
+		if _, err := m.RegisterEvents(cniProfile.Events(m)...); err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+// registerK8sEvents registers the K8s API events which are only available when a K8s clientset is configured
+func (m *Measurer) registerK8sEvents() (*Measurer, error) {
 	return m.RegisterEvents([]*sources.Event{
 		{
 			Name:          "Pod Created",
@@ -473,155 +870,145 @@ func (m *Measurer) RegisterDefaultEvents() (*Measurer, error) {
 			MatchSelector: sources.EventMatchSelectorFirst,
 			FindFn:        lo.Must(m.GetSource(k8ssrc.Name)).(*k8ssrc.Source).FindPodCreationTime(),
 		},
+	}...)
+}
+
+// registerEC2Events registers the EC2 and Auto Scaling API events which are only available when an EC2 client is configured
+func (m *Measurer) registerEC2Events() (*Measurer, error) {
+	ec2Source := lo.Must(m.GetSource(ec2src.Name)).(*ec2src.Source)
+	return m.RegisterEvents([]*sources.Event{
 		{
 			Name:          "Fleet Requested",
 			Metric:        "fleet_requested",
 			SrcName:       ec2src.Name,
 			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(ec2src.Name)).(*ec2src.Source).FindFleetStart(),
-		},
-		{
-			Name:          "Instance Pending",
-			Metric:        "instance_pending",
-			SrcName:       imdssrc.Name,
-			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(imdssrc.Name)).(*imdssrc.Source).FindByPath(imdssrc.PendingTime),
-		},
-		{
-			Name:          "VM Initialized",
-			Metric:        "vm_initialized",
-			SrcName:       messages.Name,
-			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(vmInit),
-		},
-		{
-			Name:          "Network Start",
-			Metric:        "network_start",
-			SrcName:       messages.Name,
-			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(networkStart),
-		},
-		{
-			Name:          "Network Ready",
-			Metric:        "network_ready",
-			SrcName:       messages.Name,
-			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(networkReady),
+			FindFn:        ec2Source.FindFleetStart(),
 		},
 		{
-			Name:          "Cloud-Init Initial Start",
-			Metric:        "cloudinit_initial_start",
-			SrcName:       messages.Name,
+			Name:          "Capacity Requested",
+			Metric:        "capacity_requested",
+			SrcName:       ec2src.Name,
 			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(cloudInitInitialStart),
+			CommentFn:     ec2src.CommentLaunchMethod(),
+			FindFn:        ec2Source.FindCapacityRequested(),
 		},
 		{
-			Name:          "Cloud-Init Config Start",
-			Metric:        "cloudinit_config_start",
-			SrcName:       messages.Name,
+			Name:          "Launch Successful",
+			Metric:        "launch_successful",
+			SrcName:       ec2src.Name,
 			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(cloudInitConfigStart),
+			CommentFn:     ec2src.CommentLaunchMethod(),
+			FindFn:        ec2Source.FindLaunchSuccessful(),
 		},
 		{
-			Name:          "Cloud-Init Final Start",
-			Metric:        "cloudinit_final_start",
-			SrcName:       messages.Name,
+			Name:          "Instance Launched",
+			Metric:        "instance_launched",
+			SrcName:       ec2src.Name,
 			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(cloudInitFinalStart),
+			FindFn:        ec2Source.FindLaunchTime(),
 		},
 		{
-			Name:          "Cloud-Init Final Finish",
-			Metric:        "cloudinit_final_finish",
-			SrcName:       messages.Name,
+			Name:          "Primary ENI Attached",
+			Metric:        "primary_eni_attached",
+			SrcName:       ec2src.Name,
 			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(cloudInitFinalFinish),
+			CommentFn:     ec2src.CommentAttachment(),
+			FindFn:        ec2Source.FindPrimaryENIAttach(),
 		},
 		{
-			Name:          "Containerd Start",
-			Metric:        "conatinerd_start",
-			SrcName:       messages.Name,
-			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(containerdStart),
+			Name:          "VPC CNI ENI Attached",
+			Metric:        "vpc_cni_eni_attached",
+			SrcName:       ec2src.Name,
+			MatchSelector: sources.EventMatchSelectorAll,
+			CommentFn:     ec2src.CommentAttachment(),
+			FindFn:        ec2Source.FindVPCCNIENIAttach(),
 		},
 		{
-			Name:          "Containerd Initialized",
-			Metric:        "conatinerd_initialized",
-			SrcName:       messages.Name,
-			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(containerdInitialized),
+			Name:          "EBS Volume Attached",
+			Metric:        "ebs_volume_attached",
+			SrcName:       ec2src.Name,
+			MatchSelector: sources.EventMatchSelectorAll,
+			CommentFn:     ec2src.CommentAttachment(),
+			FindFn:        ec2Source.FindEBSAttach(),
 		},
+	}...)
+}
+
+// registerIMDSEvents registers the EC2 IMDS events which are only available when running on EC2
+func (m *Measurer) registerIMDSEvents() (*Measurer, error) {
+	imdsSource := lo.Must(m.GetSource(imdssrc.Name)).(*imdssrc.Source)
+	return m.RegisterEvents([]*sources.Event{
 		{
-			Name:          "Kubelet Start",
-			Metric:        "kubelet_start",
-			SrcName:       messages.Name,
+			Name:          "Instance Pending",
+			Metric:        "instance_pending",
+			SrcName:       imdssrc.Name,
 			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(kubeletStart),
+			FindFn:        imdsSource.FindByPath(imdssrc.PendingTime),
 		},
 		{
-			Name:          "Kubelet Initialized",
-			Metric:        "kubelet_initialized",
-			SrcName:       messages.Name,
+			Name:          "Spot Interruption Notice",
+			Metric:        "spot_interruption_notice",
+			SrcName:       imdssrc.Name,
 			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(kubeletInitialized),
+			CommentFn:     imdssrc.CommentValue(),
+			FindFn:        imdsSource.FindSpotInstanceAction(),
 		},
 		{
-			Name:          "Kubelet Registered",
-			Metric:        "kubelet_registered",
-			SrcName:       messages.Name,
+			Name:          "Rebalance Recommendation",
+			Metric:        "rebalance_recommendation",
+			SrcName:       imdssrc.Name,
 			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(kubeletRegistered),
+			FindFn:        imdsSource.FindRebalanceRecommendation(),
 		},
 		{
-			Name:          "Kube-Proxy Start",
-			Metric:        "kube_proxy_start",
-			SrcName:       messages.Name,
-			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(kubeProxyStart),
+			Name:          "Scheduled Maintenance",
+			Metric:        "scheduled_maintenance",
+			SrcName:       imdssrc.Name,
+			MatchSelector: sources.EventMatchSelectorAll,
+			CommentFn:     imdssrc.CommentValue(),
+			FindFn:        imdsSource.FindScheduledMaintenance(),
 		},
+	}...)
+}
+
+// registerKarpenterEvents registers the NodeClaim lifecycle events which are only available on nodes launched by Karpenter
+func (m *Measurer) registerKarpenterEvents() (*Measurer, error) {
+	karpenter := lo.Must(m.GetSource(karpentersrc.Name)).(*karpentersrc.Source)
+	return m.RegisterEvents([]*sources.Event{
 		{
-			Name:          "VPC CNI Init Start",
-			Metric:        "vpc_cni_init_start",
-			SrcName:       messages.Name,
+			Name:          "NodeClaim Created",
+			Metric:        "nodeclaim_created",
+			SrcName:       karpentersrc.Name,
 			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(vpcCNIInitStart),
+			FindFn:        karpenter.FindCondition(karpentersrc.ConditionCreated),
 		},
 		{
-			Name:          "AWS Node Start",
-			Metric:        "aws_node_start",
-			SrcName:       messages.Name,
+			Name:          "NodeClaim Launched",
+			Metric:        "nodeclaim_launched",
+			SrcName:       karpentersrc.Name,
 			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(awsNodeStart),
+			FindFn:        karpenter.FindCondition(karpentersrc.ConditionLaunched),
 		},
 		{
-			Name:          "VPC CNI Plugin Initialized",
-			Metric:        "vpc_cni_plugin_initialized",
-			SrcName:       awsnode.Name,
+			Name:          "NodeClaim Registered",
+			Metric:        "nodeclaim_registered",
+			SrcName:       karpentersrc.Name,
 			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(awsnode.Name)).(*awsnode.Source).FindByRegex(vpcCNIInitialized),
-		},
-		{
-			Name:          "Kube-APIServer Throttled",
-			Metric:        "kube_apiserver_throttled",
-			SrcName:       messages.Name,
-			MatchSelector: sources.EventMatchSelectorAll,
-			CommentFn:     sources.CommentMatchedLine(),
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(throttled),
+			FindFn:        karpenter.FindCondition(karpentersrc.ConditionRegistered),
 		},
 		{
-			Name:          "Node Ready",
-			Metric:        "node_ready",
-			SrcName:       messages.Name,
-			Terminal:      true,
+			Name:          "NodeClaim Initialized",
+			Metric:        "nodeclaim_initialized",
+			SrcName:       karpentersrc.Name,
 			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(nodeReady),
+			FindFn:        karpenter.FindCondition(karpentersrc.ConditionInitialized),
 		},
 		{
-			Name:          "Pod Ready",
-			Metric:        "pod_ready",
-			SrcName:       messages.Name,
-			Terminal:      true,
+			Name:          "NodeClaim Ready",
+			Metric:        "nodeclaim_ready",
+			SrcName:       karpentersrc.Name,
 			MatchSelector: sources.EventMatchSelectorFirst,
-			FindFn:        lo.Must(m.GetSource(messages.Name)).(*messages.Source).FindByRegex(regexp.MustCompile(fmt.Sprintf(podReadyStr, m.podNamespace))),
+			FindFn:        karpenter.FindCondition(karpentersrc.ConditionReady),
 		},
 	}...)
 }
@@ -0,0 +1,467 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+var (
+	// OSReleasePath is the os-release file read by the local metadata provider
+	OSReleasePath = "/etc/os-release"
+	// DMIPath is the directory of the DMI (SMBIOS) identifiers read by the local metadata provider
+	DMIPath = "/sys/class/dmi/id"
+	// KernelReleasePath is the kernel release read by the local metadata provider
+	KernelReleasePath = "/proc/sys/kernel/osrelease"
+)
+
+// Well-known K8s node labels read by the K8s Node metadata provider
+const (
+	instanceTypeLabel = "node.kubernetes.io/instance-type"
+	zoneLabel         = "topology.kubernetes.io/zone"
+	regionLabel       = "topology.kubernetes.io/region"
+	archLabel         = "kubernetes.io/arch"
+)
+
+var (
+	// awsNodeSelector selects the VPC CNI pods whose image tag is the CNI version
+	awsNodeSelector = "k8s-app=aws-node"
+	// Versions logged by the kernel, containerd, kubelet, and the image pull of the VPC CNI during boot
+	kernelVersionRegex     = regexp.MustCompile(`kernel: (?:\[ *[0-9.]+\] )?Linux version (\S+)`)
+	containerdVersionRegex = regexp.MustCompile(`starting containerd".* version=(\S+)`)
+	kubeletVersionRegex    = regexp.MustCompile(`"Kubelet version" kubeletVersion="([^"]+)"`)
+	cniVersionRegex        = regexp.MustCompile(`PullImage \\?"[^"\\]*/amazon-k8s-cni:([^"\\]+)\\?"`)
+)
+
+// MetadataProvider provides data about the node where measurements are executed
+type MetadataProvider interface {
+	// Name is the name of the provider
+	Name() string
+	// Metadata returns the node's metadata or an error if the provider is not available in the environment
+	Metadata(ctx context.Context) (*Metadata, error)
+}
+
+// IMDSMetadataProvider provides metadata from the EC2 instance-identity document
+type IMDSMetadataProvider struct {
+	imdsClient *imds.Client
+	apiCalls   *sources.APICalls
+}
+
+// NewIMDSMetadataProvider creates a metadata provider for the EC2 instance-identity document
+func NewIMDSMetadataProvider(imdsClient *imds.Client) *IMDSMetadataProvider {
+	return &IMDSMetadataProvider{imdsClient: imdsClient, apiCalls: &sources.APICalls{}}
+}
+
+// Name is the name of the provider
+func (p *IMDSMetadataProvider) Name() string {
+	return "EC2 IMDS"
+}
+
+// Metadata returns the metadata from the EC2 instance-identity document
+func (p *IMDSMetadataProvider) Metadata(ctx context.Context) (*Metadata, error) {
+	p.apiCalls.Inc("GetInstanceIdentityDocument")
+	idDoc, err := p.imdsClient.GetInstanceIdentityDocument(ctx, &imds.GetInstanceIdentityDocumentInput{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve instance-identity document: %w", err)
+	}
+	return &Metadata{
+		Region:           idDoc.Region,
+		InstanceType:     idDoc.InstanceType,
+		InstanceID:       idDoc.InstanceID,
+		AccountID:        idDoc.AccountID,
+		Architecture:     idDoc.Architecture,
+		AvailabilityZone: idDoc.AvailabilityZone,
+		AMIID:            idDoc.ImageID,
+		PrivateIP:        idDoc.PrivateIP,
+	}, nil
+}
+
+// K8sNodeMetadataProvider provides metadata from the well-known labels and the node info of the K8s Node
+type K8sNodeMetadataProvider struct {
+	clientset *kubernetes.Clientset
+	nodeName  string
+	apiCalls  *sources.APICalls
+}
+
+// NewK8sNodeMetadataProvider creates a metadata provider for the K8s Node
+func NewK8sNodeMetadataProvider(clientset *kubernetes.Clientset, nodeName string) *K8sNodeMetadataProvider {
+	return &K8sNodeMetadataProvider{clientset: clientset, nodeName: nodeName, apiCalls: &sources.APICalls{}}
+}
+
+// Name is the name of the provider
+func (p *K8sNodeMetadataProvider) Name() string {
+	return "K8s Node"
+}
+
+// Metadata returns the metadata from the K8s Node
+func (p *K8sNodeMetadataProvider) Metadata(ctx context.Context) (*Metadata, error) {
+	if p.nodeName == "" {
+		return nil, errors.New("node name is not known")
+	}
+	p.apiCalls.Inc("GetNode")
+	node, err := p.clientset.CoreV1().Nodes().Get(ctx, p.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get node %s: %w", p.nodeName, err)
+	}
+	internalIP, _ := lo.Find(node.Status.Addresses, func(a corev1.NodeAddress) bool { return a.Type == corev1.NodeInternalIP })
+	return &Metadata{
+		Region:                  node.Labels[regionLabel],
+		InstanceType:            node.Labels[instanceTypeLabel],
+		InstanceID:              providerInstanceID(node.Spec.ProviderID),
+		Architecture:            normalizeArchitecture(lo.CoalesceOrEmpty(node.Labels[archLabel], node.Status.NodeInfo.Architecture)),
+		AvailabilityZone:        node.Labels[zoneLabel],
+		PrivateIP:               internalIP.Address,
+		OSImage:                 node.Status.NodeInfo.OSImage,
+		KernelVersion:           node.Status.NodeInfo.KernelVersion,
+		KubeletVersion:          node.Status.NodeInfo.KubeletVersion,
+		ContainerRuntimeVersion: node.Status.NodeInfo.ContainerRuntimeVersion,
+		CNIVersion:              p.cniVersion(ctx),
+		nodeLabels:              node.Labels,
+		nodeAnnotations:         node.Annotations,
+	}, nil
+}
+
+// cniVersion returns the image tag of the node's aws-node pod or an empty string if the VPC CNI is not running on the node
+func (p *K8sNodeMetadataProvider) cniVersion(ctx context.Context) string {
+	p.apiCalls.Inc("ListPods")
+	pods, err := p.clientset.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{
+		LabelSelector: awsNodeSelector,
+		FieldSelector: fmt.Sprintf("spec.nodeName=%s", p.nodeName),
+	})
+	if err != nil || len(pods.Items) == 0 {
+		return ""
+	}
+	container, ok := lo.Find(pods.Items[0].Spec.Containers, func(c corev1.Container) bool { return c.Name == "aws-node" })
+	if !ok {
+		return ""
+	}
+	return imageTag(container.Image)
+}
+
+// LogMetadataProvider provides the kernel, containerd, kubelet, and VPC CNI versions logged during the most recent boot
+type LogMetadataProvider struct {
+	systemLog sources.RegexFinder
+}
+
+// NewLogMetadataProvider creates a metadata provider for the versions logged to the system log (i.e. /var/log/messages or the journal)
+func NewLogMetadataProvider(systemLog sources.RegexFinder) *LogMetadataProvider {
+	return &LogMetadataProvider{systemLog: systemLog}
+}
+
+// Name is the name of the provider
+func (p *LogMetadataProvider) Name() string {
+	return "Log"
+}
+
+// Metadata returns the versions logged during the most recent boot, it is only unavailable if no versions are logged
+func (p *LogMetadataProvider) Metadata(ctx context.Context) (*Metadata, error) {
+	find := func(re *regexp.Regexp) string {
+		lines, err := p.systemLog.FindByRegex(re)(ctx, p.systemLog, nil)
+		if err != nil || len(lines) == 0 {
+			return ""
+		}
+		// the last match is used since earlier matches may be from previous boots
+		if match := re.FindStringSubmatch(lines[len(lines)-1]); len(match) == 2 {
+			return match[1]
+		}
+		return ""
+	}
+	metadata := &Metadata{
+		KernelVersion:  find(kernelVersionRegex),
+		KubeletVersion: find(kubeletVersionRegex),
+		CNIVersion:     find(cniVersionRegex),
+	}
+	if containerdVersion := find(containerdVersionRegex); containerdVersion != "" {
+		metadata.ContainerRuntimeVersion = "containerd://" + containerdVersion
+	}
+	if metadata.KernelVersion == "" && metadata.KubeletVersion == "" && metadata.CNIVersion == "" && metadata.ContainerRuntimeVersion == "" {
+		return nil, fmt.Errorf("no versions found in %s", p.systemLog)
+	}
+	return metadata, nil
+}
+
+// LocalMetadataProvider provides metadata from local files: os-release, the DMI identifiers, and the kernel release
+type LocalMetadataProvider struct{}
+
+// NewLocalMetadataProvider creates a metadata provider for local files
+func NewLocalMetadataProvider() *LocalMetadataProvider {
+	return &LocalMetadataProvider{}
+}
+
+// Name is the name of the provider
+func (p *LocalMetadataProvider) Name() string {
+	return "Local"
+}
+
+// Metadata returns the metadata from local files, it is only unavailable if none of the files can be read
+func (p *LocalMetadataProvider) Metadata(_ context.Context) (*Metadata, error) {
+	osRelease, osErr := ParseOSRelease(OSReleasePath)
+	kernelRelease, kernelErr := os.ReadFile(KernelReleasePath)
+	productName, dmiErr := os.ReadFile(filepath.Join(DMIPath, "product_name"))
+	if osErr != nil && kernelErr != nil && dmiErr != nil {
+		return nil, fmt.Errorf("unable to read local metadata: %w", errors.Join(osErr, kernelErr, dmiErr))
+	}
+	return &Metadata{
+		InstanceType:  strings.TrimSpace(string(productName)),
+		Architecture:  normalizeArchitecture(runtime.GOARCH),
+		OSImage:       osRelease["PRETTY_NAME"],
+		KernelVersion: strings.TrimSpace(string(kernelRelease)),
+	}, nil
+}
+
+// ParseOSRelease parses an os-release file into its key value pairs
+func ParseOSRelease(path string) (map[string]string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	osRelease := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		osRelease[key] = strings.Trim(value, `"'`)
+	}
+	return osRelease, scanner.Err()
+}
+
+// WithMetadataProviders overrides the default metadata providers, the first available provider's metadata is used
+// and the empty fields are filled in by the remaining providers
+func (m *Measurer) WithMetadataProviders(providers ...MetadataProvider) *Measurer {
+	m.metadataProviders = providers
+	return m
+}
+
+// defaultMetadataProviders returns the EC2 IMDS, K8s Node, log, and local providers that are available to the Measurer
+func (m *Measurer) defaultMetadataProviders(ctx context.Context) []MetadataProvider {
+	var providers []MetadataProvider
+	if m.imdsClient != nil {
+		providers = append(providers, &IMDSMetadataProvider{imdsClient: m.imdsClient, apiCalls: &m.apiCalls})
+	}
+	if m.k8sClientset != nil {
+		// the node name is best effort since it may be discovered via EC2 IMDS
+		nodeName, _ := m.discoverNodeName(ctx)
+		providers = append(providers, &K8sNodeMetadataProvider{clientset: m.k8sClientset, nodeName: nodeName, apiCalls: &m.k8sAPICalls})
+	}
+	if systemLog, ok := m.GetSource(m.Profile().SystemLog); ok {
+		if finder, ok := systemLog.(sources.RegexFinder); ok {
+			providers = append(providers, NewLogMetadataProvider(finder))
+		}
+	}
+	return append(providers, NewLocalMetadataProvider())
+}
+
+// metadataRefresh schedules the retries of the metadata providers until the versions missing from the cached metadata are known
+type metadataRefresh struct {
+	// providers are the names of the providers that failed or reported versions, the others are not retried
+	providers []string
+	failures  int
+	retryAt   time.Time
+	err       error
+	// nodeDimensionsMapped is set once the node labels and annotations have been mapped to dimensions
+	nodeDimensionsMapped bool
+}
+
+// getMetadataProviders returns the configured or the default metadata providers
+func (m *Measurer) getMetadataProviders(ctx context.Context) []MetadataProvider {
+	if m.metadataProviders != nil {
+		return m.metadataProviders
+	}
+	return m.defaultMetadataProviders(ctx)
+}
+
+// getMetadata populates the metadata for a Measurement from the metadata providers. Each provider is queried once and the metadata is
+// cached, the providers that may report the versions that are not known yet are retried with the same backoff as failing sources.
+func (m *Measurer) getMetadata(ctx context.Context) (*Metadata, error) {
+	refresh := &m.metadataRefresh
+	if time.Now().Before(refresh.retryAt) {
+		return m.metadata, refresh.err
+	}
+	if m.metadata != nil {
+		if !m.hasVersions(m.metadata) {
+			m.refreshMetadata(ctx)
+		}
+		return m.metadata, nil
+	}
+	var metadata *Metadata
+	var errs error
+	for _, provider := range m.getMetadataProviders(ctx) {
+		providerMetadata, err := provider.Metadata(ctx)
+		if err != nil || providerMetadata.hasAnyVersion() {
+			refresh.providers = append(refresh.providers, provider.Name())
+		}
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s metadata provider: %w", provider.Name(), err))
+			continue
+		}
+		if metadata == nil {
+			metadata = providerMetadata
+			continue
+		}
+		metadata.fillFrom(providerMetadata)
+	}
+	if metadata == nil {
+		refresh.providers = nil
+		refresh.failures++
+		refresh.retryAt, refresh.err = backoffRetryAt(time.Now(), refresh.failures), errs
+		return nil, errs
+	}
+	if m.imdsClient != nil {
+		metadata.Extra = lo.Assign(metadata.Extra, m.getIMDSDimensions(ctx))
+	}
+	m.metadata, refresh.err = metadata, nil
+	m.mapNodeDimensions()
+	if !m.hasVersions(m.metadata) {
+		refresh.failures = 1
+		refresh.retryAt = backoffRetryAt(time.Now(), refresh.failures)
+	}
+	return m.metadata, nil
+}
+
+// refreshMetadata retries the providers that may report the missing versions and fills in only the empty fields of the cached metadata
+func (m *Measurer) refreshMetadata(ctx context.Context) {
+	refresh := &m.metadataRefresh
+	for _, provider := range m.getMetadataProviders(ctx) {
+		if !lo.Contains(refresh.providers, provider.Name()) {
+			continue
+		}
+		providerMetadata, err := provider.Metadata(ctx)
+		if err != nil {
+			continue
+		}
+		m.metadata.fillFrom(providerMetadata)
+		if m.hasVersions(m.metadata) {
+			break
+		}
+	}
+	m.mapNodeDimensions()
+	refresh.failures++
+	refresh.retryAt = backoffRetryAt(time.Now(), refresh.failures)
+}
+
+// mapNodeDimensions maps the node labels and annotations to dimensions once the node has registered, so that each missing
+// label or annotation is only logged once. Static dimensions take precedence over dimensions mapped from the node.
+func (m *Measurer) mapNodeDimensions() {
+	if m.metadataRefresh.nodeDimensionsMapped {
+		return
+	}
+	if m.metadata.nodeLabels != nil {
+		m.metadataRefresh.nodeDimensionsMapped = true
+		m.metadata.Extra = lo.Assign(m.metadata.Extra,
+			mapDimensions("node label", m.labelDimensions, m.metadata.nodeLabels),
+			mapDimensions("node annotation", m.annotDimensions, m.metadata.nodeAnnotations))
+	}
+	m.metadata.Extra = lo.Assign(m.metadata.Extra, m.dimensions)
+}
+
+// hasVersions returns true if the kubelet version and the CNI are known, and the VPC CNI version if the VPC CNI is used.
+// They are only available once the kubelet has started and the CNI image has been pulled, which may be after the first timing run.
+func (m *Measurer) hasVersions(metadata *Metadata) bool {
+	if metadata.KubeletVersion == "" {
+		return false
+	}
+	if m.detectCNIProfiles() != nil {
+		return false
+	}
+	return metadata.CNIVersion != "" || !lo.Contains(m.cniProfiles, CNIProfileVPC)
+}
+
+// hasAnyVersion returns true if any of the versions that are logged or reported by the node is set
+func (md *Metadata) hasAnyVersion() bool {
+	return md.KernelVersion != "" || md.KubeletVersion != "" || md.ContainerRuntimeVersion != "" || md.CNIVersion != ""
+}
+
+// fillFrom sets the empty fields of the Metadata from other Metadata
+func (md *Metadata) fillFrom(other *Metadata) {
+	fill := func(field *string, value string) {
+		*field = lo.CoalesceOrEmpty(*field, value)
+	}
+	fill(&md.Region, other.Region)
+	fill(&md.InstanceType, other.InstanceType)
+	fill(&md.InstanceID, other.InstanceID)
+	fill(&md.AccountID, other.AccountID)
+	fill(&md.Architecture, other.Architecture)
+	fill(&md.AvailabilityZone, other.AvailabilityZone)
+	fill(&md.PrivateIP, other.PrivateIP)
+	fill(&md.AMIID, other.AMIID)
+	fill(&md.OSImage, other.OSImage)
+	fill(&md.KernelVersion, other.KernelVersion)
+	fill(&md.KubeletVersion, other.KubeletVersion)
+	fill(&md.ContainerRuntimeVersion, other.ContainerRuntimeVersion)
+	fill(&md.CNIVersion, other.CNIVersion)
+	md.Extra = lo.Assign(other.Extra, md.Extra)
+	md.nodeLabels = lo.Ternary(md.nodeLabels == nil, other.nodeLabels, md.nodeLabels)
+	md.nodeAnnotations = lo.Ternary(md.nodeAnnotations == nil, other.nodeAnnotations, md.nodeAnnotations)
+}
+
+// mapDimensions maps the values of keys (i.e. node labels) to dimensions, keys the node does not have are omitted
+func mapDimensions(kind string, dimensions map[string]string, values map[string]string) map[string]string {
+	result := map[string]string{}
+	for dimension, key := range dimensions {
+		value, ok := values[key]
+		if !ok {
+			log.Printf("unable to map %s %s to dimension %s: the node does not have it", kind, key, dimension)
+			continue
+		}
+		result[dimension] = value
+	}
+	return result
+}
+
+// providerInstanceID returns the instance ID of a K8s provider ID (i.e. aws:///us-west-2a/i-0123456789abcdef0)
+func providerInstanceID(providerID string) string {
+	if providerID == "" {
+		return ""
+	}
+	return providerID[strings.LastIndex(providerID, "/")+1:]
+}
+
+// imageTag returns the tag of a container image reference (i.e. v1.12.0 of 602401143452.dkr.ecr.us-west-2.amazonaws.com/amazon-k8s-cni:v1.12.0)
+func imageTag(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return ""
+}
+
+// normalizeArchitecture uses the EC2 architecture names so that dimensions match across providers
+func normalizeArchitecture(arch string) string {
+	if arch == "amd64" {
+		return "x86_64"
+	}
+	return arch
+}
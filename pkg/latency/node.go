@@ -0,0 +1,251 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// Node annotation and label consts
+const (
+	NodeAnnotationPrefix        = "node-latency.k8s.aws/"
+	NodeAnnotationSummary       = NodeAnnotationPrefix + "summary"
+	NodeLabelReadyBucket        = NodeAnnotationPrefix + "ready-bucket"
+	nodeAnnotationSecondsSuffix = "-seconds"
+	nodeReadyMetric             = "node_ready"
+)
+
+// Node event consts
+const (
+	NodeEventReasonSlow     = "NodeLaunchSlow"
+	NodeEventReasonMeasured = "NodeLaunchMeasured"
+	nodeEventComponent      = "node-latency-for-k8s"
+	// nodeEventNamespace is the namespace events for cluster scoped objects like Nodes are recorded in
+	nodeEventNamespace   = metav1.NamespaceDefault
+	defaultSlowestPhases = 3
+)
+
+// DefaultNodeReadyBuckets are the default upper bounds used to bucket the node ready timing into a label
+var DefaultNodeReadyBuckets = []time.Duration{30 * time.Second, 60 * time.Second, 90 * time.Second, 120 * time.Second, 180 * time.Second, 300 * time.Second}
+
+// NodeAnnotationOptions configures how a Measurement is written to the Node object
+type NodeAnnotationOptions struct {
+	// BucketLabel adds a label bucketing the BucketMetric timing (i.e. node-latency.k8s.aws/ready-bucket=lt60s)
+	BucketLabel bool
+	// BucketMetric is the metric used for the bucket label, defaults to node_ready
+	BucketMetric string
+	// Buckets are the upper bounds of the buckets, defaults to DefaultNodeReadyBuckets
+	Buckets []time.Duration
+}
+
+// AnnotateNode patches the measured Node with an annotation per metric timing, a compact JSON summary annotation,
+// and optionally a label bucketing the node ready timing so that timings are visible from `kubectl describe node`
+func (m *Measurer) AnnotateNode(ctx context.Context, measurement *Measurement, opts NodeAnnotationOptions) error {
+	if m.k8sClientset == nil {
+		return errors.New("unable to annotate node because the k8s clientset is nil")
+	}
+	nodeName, err := m.discoverNodeName(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to annotate node: %w", err)
+	}
+	metadata := map[string]map[string]string{
+		"annotations": measurement.nodeAnnotations(),
+	}
+	if labels := measurement.nodeLabels(opts); len(labels) != 0 {
+		metadata["labels"] = labels
+	}
+	patch, err := json.Marshal(map[string]any{"metadata": metadata})
+	if err != nil {
+		return fmt.Errorf("unable to marshal node patch: %w", err)
+	}
+	if _, err := m.k8sClientset.CoreV1().Nodes().Patch(ctx, nodeName, k8stypes.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("unable to patch node %s: %w", nodeName, err)
+	}
+	return nil
+}
+
+// NodeEventOptions configures the K8s Events recorded against the Node object
+type NodeEventOptions struct {
+	// Thresholds are the latency budgets per metric, a Warning event is recorded if any timing exceeds its budget
+	Thresholds map[string]time.Duration
+	// Summary records a Normal event summarizing the measurement
+	Summary bool
+	// SlowestPhases is the number of slowest phases listed in the event message, defaults to 3
+	SlowestPhases int
+}
+
+// RecordNodeEvents records a Warning event against the measured Node if any metric exceeds its threshold
+// and optionally a Normal summary event. Event messages list the slowest phases of the measurement.
+func (m *Measurer) RecordNodeEvents(ctx context.Context, measurement *Measurement, opts NodeEventOptions) error {
+	if m.k8sClientset == nil {
+		return errors.New("unable to record node events because the k8s clientset is nil")
+	}
+	nodeName, err := m.discoverNodeName(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to record node events: %w", err)
+	}
+	slowestPhases := measurement.slowestPhases(lo.Ternary(opts.SlowestPhases > 0, opts.SlowestPhases, defaultSlowestPhases))
+	var errs error
+	if exceeded := measurement.exceededThresholds(opts.Thresholds); len(exceeded) != 0 {
+		errs = multierr.Append(errs, m.recordNodeEvent(ctx, nodeName, corev1.EventTypeWarning, NodeEventReasonSlow, eventMessage(exceeded, slowestPhases)))
+	}
+	if opts.Summary {
+		summaryMetrics := lo.Ternary(len(opts.Thresholds) != 0, lo.Keys(opts.Thresholds), []string{nodeReadyMetric})
+		summary := lo.FilterMap(summaryMetrics, func(metric string, _ int) (string, bool) {
+			timing, ok := measurement.firstTiming(metric)
+			if !ok {
+				return "", false
+			}
+			return fmt.Sprintf("%s=%.0fs", metric, timing.T.Seconds()), true
+		})
+		sort.Strings(summary)
+		errs = multierr.Append(errs, m.recordNodeEvent(ctx, nodeName, corev1.EventTypeNormal, NodeEventReasonMeasured, eventMessage(summary, slowestPhases)))
+	}
+	return errs
+}
+
+// recordNodeEvent creates an Event for the Node the same way the kubelet does so that it shows up in `kubectl describe node`
+func (m *Measurer) recordNodeEvent(ctx context.Context, nodeName string, eventType string, reason string, message string) error {
+	now := metav1.Now()
+	if _, err := m.k8sClientset.CoreV1().Events(nodeEventNamespace).Create(ctx, &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: nodeName + ".",
+			Namespace:    nodeEventNamespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Node",
+			Name:       nodeName,
+			// the kubelet records node events with the node name as the UID which kubectl relies on
+			UID: k8stypes.UID(nodeName),
+		},
+		Reason:              reason,
+		Message:             message,
+		Type:                eventType,
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+		Source:              corev1.EventSource{Component: nodeEventComponent, Host: nodeName},
+		ReportingController: nodeEventComponent,
+		ReportingInstance:   nodeName,
+	}, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("unable to record %s event for node %s: %w", reason, nodeName, err)
+	}
+	return nil
+}
+
+// exceededThresholds returns a description of each metric whose first successful timing exceeds its threshold
+func (m *Measurement) exceededThresholds(thresholds map[string]time.Duration) []string {
+	var exceeded []string
+	for metric, threshold := range thresholds {
+		timing, ok := m.firstTiming(metric)
+		if !ok || timing.T <= threshold {
+			continue
+		}
+		exceeded = append(exceeded, fmt.Sprintf("%s=%.0fs exceeds %.0fs", metric, timing.T.Seconds(), threshold.Seconds()))
+	}
+	sort.Strings(exceeded)
+	return exceeded
+}
+
+// slowestPhases returns a description of the n longest phases
+func (m *Measurement) slowestPhases(n int) []string {
+	phaseTimings := m.PhaseTimings()
+	sort.SliceStable(phaseTimings, func(i, j int) bool {
+		return phaseTimings[i].Duration > phaseTimings[j].Duration
+	})
+	if len(phaseTimings) > n {
+		phaseTimings = phaseTimings[:n]
+	}
+	return lo.Map(phaseTimings, func(pt *PhaseTiming, _ int) string {
+		return fmt.Sprintf("%s=%.0fs", pt.Phase.Name, pt.Duration.Seconds())
+	})
+}
+
+// eventMessage formats the event message from the timings and slowest phases
+func eventMessage(timings []string, slowestPhases []string) string {
+	message := strings.Join(timings, ", ")
+	if len(slowestPhases) != 0 {
+		message = fmt.Sprintf("%s; slowest phases: %s", message, strings.Join(slowestPhases, ", "))
+	}
+	return message
+}
+
+// nodeAnnotations constructs an annotation per metric using the first successful timing along with a JSON summary of all metrics
+func (m *Measurement) nodeAnnotations() map[string]string {
+	summary := map[string]float64{}
+	annotations := map[string]string{}
+	for _, t := range m.successfulTimings() {
+		if _, ok := summary[t.Event.Metric]; ok {
+			continue
+		}
+		seconds := roundSeconds(t.T)
+		summary[t.Event.Metric] = seconds
+		annotations[NodeAnnotationPrefix+strings.ReplaceAll(t.Event.Metric, "_", "-")+nodeAnnotationSecondsSuffix] = strconv.FormatFloat(seconds, 'f', -1, 64)
+	}
+	// json.Marshal sorts map keys so the summary is stable
+	summaryJSON, err := json.Marshal(summary)
+	if err == nil {
+		annotations[NodeAnnotationSummary] = string(summaryJSON)
+	}
+	return annotations
+}
+
+// nodeLabels constructs the optional bucket label for the configured metric
+func (m *Measurement) nodeLabels(opts NodeAnnotationOptions) map[string]string {
+	if !opts.BucketLabel {
+		return nil
+	}
+	metric := lo.Ternary(opts.BucketMetric != "", opts.BucketMetric, nodeReadyMetric)
+	timing, ok := m.firstTiming(metric)
+	if !ok {
+		return nil
+	}
+	buckets := lo.Ternary(len(opts.Buckets) != 0, opts.Buckets, DefaultNodeReadyBuckets)
+	return map[string]string{
+		NodeLabelReadyBucket: bucketFor(timing.T, buckets),
+	}
+}
+
+// bucketFor returns a label safe bucket name like "lt60s" for the first bucket the duration is less than, or "gte300s" if it exceeds all buckets
+func bucketFor(d time.Duration, buckets []time.Duration) string {
+	sorted := append([]time.Duration{}, buckets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for _, bucket := range sorted {
+		if d < bucket {
+			return fmt.Sprintf("lt%.0fs", bucket.Seconds())
+		}
+	}
+	return fmt.Sprintf("gte%.0fs", sorted[len(sorted)-1].Seconds())
+}
+
+// roundSeconds rounds a duration to millisecond precision seconds
+func roundSeconds(d time.Duration) float64 {
+	return math.Round(d.Seconds()*1000) / 1000
+}
@@ -0,0 +1,184 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+// Phase is a span of time derived from the timings of a start and an end event
+type Phase struct {
+	Name        string `json:"name"`
+	Metric      string `json:"metric"`
+	StartMetric string `json:"startMetric"`
+	EndMetric   string `json:"endMetric"`
+	// CommentDimension derives a phase for each comment of the start and end timings (i.e. a cloud-init module) which is named by the comment.
+	// The durations are emitted as a single metric with the comment as the value of this dimension (i.e. module).
+	CommentDimension string `json:"commentDimension,omitempty"`
+}
+
+// PhaseTiming is a specific instance of a Phase derived from a Measurement
+type PhaseTiming struct {
+	Phase *Phase `json:"phase"`
+	// Comment is the comment of the start and end timings of a phase with a CommentDimension
+	Comment  string        `json:"comment,omitempty"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"-"`
+}
+
+// MarshalJSON serializes the PhaseTiming Duration in seconds
+func (p PhaseTiming) MarshalJSON() ([]byte, error) {
+	type phaseTiming PhaseTiming
+	return json.Marshal(struct {
+		phaseTiming
+		Seconds float64 `json:"seconds"`
+	}{phaseTiming: phaseTiming(p), Seconds: p.Duration.Seconds()})
+}
+
+// phaseMetricSuffix is appended to the metric of a phase's duration so that it does not collide with the metrics of events
+const phaseMetricSuffix = "_duration"
+
+// DefaultPhases are the phases derived from the default events
+var DefaultPhases = []*Phase{
+	{Name: "Pod Launch", Metric: "pod_launch", StartMetric: "pod_created", EndMetric: "pod_ready"},
+	{Name: "NodeClaim Launch", Metric: "nodeclaim_launch", StartMetric: "nodeclaim_created", EndMetric: "nodeclaim_launched"},
+	{Name: "NodeClaim Initialization", Metric: "nodeclaim_initialization", StartMetric: "nodeclaim_registered", EndMetric: "nodeclaim_initialized"},
+	{Name: "Capacity Fulfillment", Metric: "capacity_fulfillment", StartMetric: "capacity_requested", EndMetric: "instance_pending"},
+	{Name: "Fleet Fulfillment", Metric: "fleet_fulfillment", StartMetric: "fleet_requested", EndMetric: "instance_pending"},
+	{Name: "VM Boot", Metric: "vm_boot", StartMetric: "instance_pending", EndMetric: "vm_initialized"},
+	{Name: "Network Setup", Metric: "network_setup", StartMetric: "network_start", EndMetric: "network_ready"},
+	{Name: "Cloud-Init", Metric: "cloudinit", StartMetric: "cloudinit_initial_start", EndMetric: "cloudinit_final_finish"},
+	{Name: "Containerd Startup", Metric: "containerd_startup", StartMetric: "conatinerd_start", EndMetric: "conatinerd_initialized"},
+	{Name: "Kubelet Startup", Metric: "kubelet_startup", StartMetric: "kubelet_start", EndMetric: "kubelet_initialized"},
+	{Name: "Kubelet Registration", Metric: "kubelet_registration", StartMetric: "kubelet_start", EndMetric: "kubelet_registered"},
+	{Name: "Node Readiness", Metric: "node_readiness", StartMetric: "kubelet_registered", EndMetric: "node_ready"},
+}
+
+// RegisterPhases registers n phases to the Measurer which will be derived from the timings of each Measurement
+func (m *Measurer) RegisterPhases(phases ...*Phase) *Measurer {
+	m.phases = append(m.phases, phases...)
+	return m
+}
+
+// PhaseTimings derives the registered phases from the successful timings of a Measurement.
+// Phases with a missing start or end timing are omitted.
+func (m *Measurement) PhaseTimings() []*PhaseTiming {
+	var phaseTimings []*PhaseTiming
+	for _, phase := range m.phases {
+		if phase.CommentDimension != "" {
+			phaseTimings = append(phaseTimings, m.commentPhaseTimings(phase)...)
+			continue
+		}
+		start, ok := m.firstTiming(phase.StartMetric)
+		if !ok {
+			continue
+		}
+		end, ok := m.firstTiming(phase.EndMetric)
+		if !ok {
+			continue
+		}
+		if phaseTiming, ok := newPhaseTiming(phase, start, end); ok {
+			phaseTimings = append(phaseTimings, phaseTiming)
+		}
+	}
+	sort.SliceStable(phaseTimings, func(i, j int) bool {
+		return phaseTimings[i].Start.Before(phaseTimings[j].Start)
+	})
+	return phaseTimings
+}
+
+// commentPhaseTimings derives a phase for each comment of the phase's start timings from the first start and end timing with that comment
+func (m *Measurement) commentPhaseTimings(phase *Phase) []*PhaseTiming {
+	var phaseTimings []*PhaseTiming
+	for _, start := range lo.UniqBy(m.successfulMetricTimings(phase.StartMetric), func(t *sources.Timing) string { return t.Comment }) {
+		if start.Comment == "" {
+			continue
+		}
+		end, ok := lo.Find(m.successfulMetricTimings(phase.EndMetric), func(t *sources.Timing) bool { return t.Comment == start.Comment })
+		if !ok {
+			continue
+		}
+		commentPhase := &Phase{
+			Name:             fmt.Sprintf("%s %s", phase.Name, start.Comment),
+			Metric:           phase.Metric,
+			StartMetric:      phase.StartMetric,
+			EndMetric:        phase.EndMetric,
+			CommentDimension: phase.CommentDimension,
+		}
+		if phaseTiming, ok := newPhaseTiming(commentPhase, start, end); ok {
+			phaseTiming.Comment = start.Comment
+			phaseTimings = append(phaseTimings, phaseTiming)
+		}
+	}
+	return phaseTimings
+}
+
+// newPhaseTiming is the span between the start and end timings, it is not ok if the end is before the start
+func newPhaseTiming(phase *Phase, start *sources.Timing, end *sources.Timing) (*PhaseTiming, bool) {
+	if end.Timestamp.Before(start.Timestamp) {
+		return nil, false
+	}
+	return &PhaseTiming{
+		Phase:    phase,
+		Start:    start.Timestamp,
+		End:      end.Timestamp,
+		Duration: end.Timestamp.Sub(start.Timestamp),
+	}, true
+}
+
+// successfulMetricTimings are the successful timings of a metric ordered by timestamp
+func (m *Measurement) successfulMetricTimings(metric string) []*sources.Timing {
+	timings := lo.Filter(m.Timings, func(t *sources.Timing, _ int) bool { return t.Event.Metric == metric && t.Error == nil })
+	sort.SliceStable(timings, func(i, j int) bool { return timings[i].Timestamp.Before(timings[j].Timestamp) })
+	return timings
+}
+
+// firstTiming finds the earliest successful timing for a metric
+func (m *Measurement) firstTiming(metric string) (*sources.Timing, bool) {
+	return lo.Find(m.Timings, func(t *sources.Timing) bool {
+		return t.Event.Metric == metric && t.Error == nil
+	})
+}
+
+// metricValue is a metric in seconds emitted to CloudWatch and Prometheus
+type metricValue struct {
+	name    string
+	seconds float64
+	// dimensions are added to the dimensions of the Measurement (i.e. the module of a cloud-init module phase)
+	dimensions map[string]string
+}
+
+// metricValues are the successful timings relative to the start of the measurement and the durations of the derived phases
+func (m *Measurement) metricValues() []metricValue {
+	values := lo.Map(m.successfulTimings(), func(t *sources.Timing, _ int) metricValue {
+		return metricValue{name: t.Event.Metric, seconds: t.T.Seconds()}
+	})
+	for _, phaseTiming := range m.PhaseTimings() {
+		value := metricValue{name: phaseTiming.Phase.Metric + phaseMetricSuffix, seconds: phaseTiming.Duration.Seconds()}
+		if phaseTiming.Phase.CommentDimension != "" {
+			value.dimensions = map[string]string{phaseTiming.Phase.CommentDimension: phaseTiming.Comment}
+		}
+		values = append(values, value)
+	}
+	return values
+}
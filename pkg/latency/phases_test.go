@@ -0,0 +1,44 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPhaseTimingMarshalsTheDurationInSeconds(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	out, err := json.Marshal(&PhaseTiming{
+		Phase:    DefaultPhases[0],
+		Start:    start,
+		End:      start.Add(1500 * time.Millisecond),
+		Duration: 1500 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var phaseTiming map[string]any
+	if err := json.Unmarshal(out, &phaseTiming); err != nil {
+		t.Fatal(err)
+	}
+	if phaseTiming["seconds"] != 1.5 {
+		t.Errorf("expected the duration to be 1.5 seconds, got %v", phaseTiming["seconds"])
+	}
+	if _, ok := phaseTiming["phase"]; !ok {
+		t.Errorf("expected the phase to be serialized, got %s", out)
+	}
+}
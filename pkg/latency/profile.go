@@ -0,0 +1,207 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources/messages"
+)
+
+// Profile is the log sources, events, and phases of a node OS
+type Profile struct {
+	// Name selects the profile (i.e. al2, al2023, bottlerocket, or ubuntu)
+	Name string
+	// Detect returns true if the profile is for the node's os-release
+	Detect func(osRelease map[string]string) bool
+	// SystemLog is the name of the source with the kernel, systemd, containerd, and kubelet logs
+	SystemLog string
+	// Sources returns the log sources of the profile
+	Sources func(m *Measurer) []sources.Source
+	// Events returns the events of the profile's sources
+	Events func(m *Measurer) []*sources.Event
+	// Phases are derived from the profile's events in addition to the DefaultPhases
+	Phases []*Phase
+}
+
+var (
+	// journal regexes match the systemd and kubelet messages in the journal, newer systemd versions include the unit name (i.e. "Starting kubelet.service - Kubelet...")
+	journalNetworkStart          = regexp.MustCompile(`.*systemd\[[0-9]+\]: Reached target (Network \(Pre\)|network-pre\.target).*`)
+	journalNetworkReady          = regexp.MustCompile(`.*systemd\[[0-9]+\]: Reached target (Network\.|network\.target).*`)
+	journalContainerdStart       = regexp.MustCompile(`.*systemd\[[0-9]+\]: Starting (containerd\.service - )?containerd container runtime.*`)
+	journalContainerdInitialized = regexp.MustCompile(`.*systemd\[[0-9]+\]: Started (containerd\.service - )?containerd container runtime.*`)
+	journalKubeletStart          = regexp.MustCompile(`.*systemd\[[0-9]+\]: Starting (kubelet\.service - )?(Kubernetes )?Kubelet.*`)
+	journalKubeletInitialized    = regexp.MustCompile(`.*kubelet\[[0-9]+\]: .*Started kubelet.*`)
+
+	// ProfileAL2 is the Amazon Linux 2 profile which reads the syslog in /var/log/messages, it is the default if no profile is detected
+	ProfileAL2 = &Profile{
+		Name: "al2",
+		Detect: func(osRelease map[string]string) bool {
+			return osRelease["ID"] == "amzn" && osRelease["VERSION_ID"] == "2"
+		},
+		SystemLog: messages.Name,
+		Sources: func(m *Measurer) []sources.Source {
+			return append([]sources.Source{
+				messages.New(messages.DefaultPath).WithCurrentBootOnly(m.currentBootOnly),
+			}, cloudInitSources(m)...)
+		},
+		Events: func(m *Measurer) []*sources.Event {
+			return lo.Flatten([][]*sources.Event{
+				systemLogEvents(messages.Name, systemLogRegexes{
+					VMInit:                vmInit,
+					NetworkStart:          networkStart,
+					NetworkReady:          networkReady,
+					ContainerdStart:       containerdStart,
+					ContainerdInitialized: containerdInitialized,
+					KubeletStart:          kubeletStart,
+					KubeletInitialized:    kubeletInitialized,
+				}),
+				cloudInitEvents(messages.Name, syslogCloudInitRegexes),
+				m.cloudInitStageEvents(),
+				containerEvents(messages.Name, m.podNamespace),
+			})
+		},
+		Phases: cloudInitPhases,
+	}
+	// Profiles are the built-in profiles in detection order
+	Profiles = []*Profile{ProfileBottlerocket, ProfileAL2023, ProfileUbuntu, ProfileAL2}
+)
+
+// systemLogRegexes are the regexes of the events logged by the kernel and systemd which differ between OSes, nil regexes are not registered
+type systemLogRegexes struct {
+	VMInit                *regexp.Regexp
+	NetworkStart          *regexp.Regexp
+	NetworkReady          *regexp.Regexp
+	ContainerdStart       *regexp.Regexp
+	ContainerdInitialized *regexp.Regexp
+	KubeletStart          *regexp.Regexp
+	KubeletInitialized    *regexp.Regexp
+}
+
+// DetectProfile returns the first of the Profiles matching the os-release file or the AL2 profile if none match
+func DetectProfile() *Profile {
+	osRelease, err := ParseOSRelease(OSReleasePath)
+	if err != nil {
+		log.Printf("unable to detect the node OS, using the %s profile: %v", ProfileAL2.Name, err)
+		return ProfileAL2
+	}
+	if profile, ok := lo.Find(Profiles, func(p *Profile) bool { return p.Detect(osRelease) }); ok {
+		return profile
+	}
+	return ProfileAL2
+}
+
+// GetProfile returns the built-in profile with the name
+func GetProfile(name string) (*Profile, error) {
+	profile, ok := lo.Find(Profiles, func(p *Profile) bool { return strings.EqualFold(p.Name, name) })
+	if !ok {
+		return nil, fmt.Errorf("profile \"%s\" must be one of %s", name, strings.Join(lo.Map(Profiles, func(p *Profile, _ int) string { return p.Name }), ", "))
+	}
+	return profile, nil
+}
+
+// WithProfile sets the profile of the node OS instead of detecting it from os-release
+func (m *Measurer) WithProfile(profile *Profile) *Measurer {
+	m.profile = profile
+	return m
+}
+
+// Profile returns the configured profile or detects the profile from os-release
+func (m *Measurer) Profile() *Profile {
+	if m.profile == nil {
+		m.profile = DetectProfile()
+	}
+	return m.profile
+}
+
+// systemLogEvents are the kernel, systemd, containerd, and kubelet service events of a system log source
+func systemLogEvents(srcName string, regexes systemLogRegexes) []*sources.Event {
+	var events []*sources.Event
+	for _, event := range []struct {
+		name   string
+		metric string
+		regex  *regexp.Regexp
+	}{
+		{name: "VM Initialized", metric: "vm_initialized", regex: regexes.VMInit},
+		{name: "Network Start", metric: "network_start", regex: regexes.NetworkStart},
+		{name: "Network Ready", metric: "network_ready", regex: regexes.NetworkReady},
+		{name: "Containerd Start", metric: "conatinerd_start", regex: regexes.ContainerdStart},
+		{name: "Containerd Initialized", metric: "conatinerd_initialized", regex: regexes.ContainerdInitialized},
+		{name: "Kubelet Start", metric: "kubelet_start", regex: regexes.KubeletStart},
+		{name: "Kubelet Initialized", metric: "kubelet_initialized", regex: regexes.KubeletInitialized},
+	} {
+		if event.regex == nil {
+			continue
+		}
+		events = append(events, &sources.Event{
+			Name:          event.name,
+			Metric:        event.metric,
+			SrcName:       srcName,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(event.regex),
+		})
+	}
+	return events
+}
+
+// containerEvents are the kubelet and containerd events which are logged the same way on every OS
+func containerEvents(srcName string, podNamespace string) []*sources.Event {
+	return []*sources.Event{
+		{
+			Name:          "Kubelet Registered",
+			Metric:        "kubelet_registered",
+			SrcName:       srcName,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(kubeletRegistered),
+		},
+		{
+			Name:          "Kube-Proxy Start",
+			Metric:        "kube_proxy_start",
+			SrcName:       srcName,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(kubeProxyStart),
+		},
+		{
+			Name:          "Kube-APIServer Throttled",
+			Metric:        "kube_apiserver_throttled",
+			SrcName:       srcName,
+			MatchSelector: sources.EventMatchSelectorAll,
+			CommentFn:     sources.CommentMatchedLine(),
+			FindFn:        sources.FindByRegex(throttled),
+		},
+		{
+			Name:          "Node Ready",
+			Metric:        "node_ready",
+			SrcName:       srcName,
+			Terminal:      true,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(nodeReady),
+		},
+		{
+			Name:          "Pod Ready",
+			Metric:        "pod_ready",
+			SrcName:       srcName,
+			Terminal:      true,
+			MatchSelector: sources.EventMatchSelectorFirst,
+			FindFn:        sources.FindByRegex(regexp.MustCompile(fmt.Sprintf(podReadyStr, podNamespace))),
+		},
+	}
+}
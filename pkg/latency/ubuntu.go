@@ -0,0 +1,66 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources/cloudinit"
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources/syslog"
+)
+
+var (
+	// Ubuntu's systemd only includes the unit name since 23.10 and the EKS Ubuntu images run kubelet as a snap (i.e. "Started Service for snap application kubelet-eks.daemon.")
+	syslogNetworkStart       = regexp.MustCompile(`.*systemd\[[0-9]+\]: Reached target (Network \(Pre\)|Preparation for Network|network-pre\.target).*`)
+	syslogKubeletStart       = regexp.MustCompile(`.*systemd\[[0-9]+\]: Start(ing|ed) .*(Kubernetes Kubelet|kubelet\.service|kubelet-eks\.daemon).*`)
+	syslogKubeletInitialized = regexp.MustCompile(`.*(kubelet|kubelet-eks\.daemon)\[[0-9]+\]: .*Started kubelet.*`)
+
+	// ProfileUbuntu is the Ubuntu and Debian profile which reads the syslog in /var/log/syslog and the cloud-init stages from /var/log/cloud-init.log
+	ProfileUbuntu = &Profile{
+		Name: "ubuntu",
+		Detect: func(osRelease map[string]string) bool {
+			return lo.ContainsBy(append([]string{osRelease["ID"]}, strings.Fields(osRelease["ID_LIKE"])...), func(id string) bool {
+				return id == "ubuntu" || id == "debian"
+			})
+		},
+		SystemLog: syslog.Name,
+		Sources: func(m *Measurer) []sources.Source {
+			return append([]sources.Source{
+				syslog.New(syslog.DefaultPath).WithCurrentBootOnly(m.currentBootOnly),
+			}, cloudInitSources(m)...)
+		},
+		Events: func(m *Measurer) []*sources.Event {
+			return lo.Flatten([][]*sources.Event{
+				systemLogEvents(syslog.Name, systemLogRegexes{
+					VMInit:                vmInit,
+					NetworkStart:          syslogNetworkStart,
+					NetworkReady:          journalNetworkReady,
+					ContainerdStart:       journalContainerdStart,
+					ContainerdInitialized: journalContainerdInitialized,
+					KubeletStart:          syslogKubeletStart,
+					KubeletInitialized:    syslogKubeletInitialized,
+				}),
+				cloudInitEvents(cloudinit.Name, cloudInitLogRegexes),
+				m.cloudInitStageEvents(),
+				containerEvents(syslog.Name, m.podNamespace),
+			})
+		},
+		Phases: cloudInitPhases,
+	}
+)
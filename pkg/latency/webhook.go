@@ -0,0 +1,132 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+const (
+	// WebhookSignatureHeader is the header containing the hex encoded HMAC-SHA256 signature of the request body
+	WebhookSignatureHeader = "X-NLK-Signature-256"
+	webhookSignaturePrefix = "sha256="
+	// DefaultWebhookRetries is the default number of retries of the webhook-retries flag
+	DefaultWebhookRetries = 3
+	defaultWebhookDelay   = time.Second
+	maxWebhookDelay       = time.Minute
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+// WebhookOptions configures how a Measurement is sent to an HTTP endpoint
+type WebhookOptions struct {
+	// URL is the endpoint the Measurement JSON is POSTed to
+	URL string
+	// Headers are added to every request
+	Headers map[string]string
+	// Retries is the number of retries after a failed request, 0 disables retries
+	Retries int
+	// RetryDelay is the initial delay before a retry which doubles after each attempt up to 1m, defaults to 1s
+	RetryDelay time.Duration
+	// Timeout is the timeout of each request, defaults to 10s
+	Timeout time.Duration
+	// HMACSecret signs the request body with HMAC-SHA256 in the WebhookSignatureHeader if set
+	HMACSecret string
+}
+
+// PostWebhook POSTs the Measurement using the same JSON schema as the json output to an HTTP endpoint.
+// Requests are retried with exponential backoff on connection errors, 429s, and 5xx responses.
+func (m *Measurement) PostWebhook(ctx context.Context, opts WebhookOptions) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("unable to marshal measurement: %w", err)
+	}
+	delay := opts.RetryDelay
+	if delay == 0 {
+		delay = defaultWebhookDelay
+	}
+	client := &http.Client{Timeout: opts.Timeout}
+	if client.Timeout == 0 {
+		client.Timeout = defaultWebhookTimeout
+	}
+	for attempt := 0; ; attempt++ {
+		retryable, err := postWebhook(ctx, client, body, opts)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= opts.Retries {
+			return fmt.Errorf("unable to post measurement to webhook after %d attempt(s): %w", attempt+1, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(webhookBackoff(delay, attempt)):
+		}
+	}
+}
+
+// webhookBackoff doubles the delay for each attempt up to maxWebhookDelay, with jitter so that many nodes do not retry at the same time
+func webhookBackoff(delay time.Duration, attempt int) time.Duration {
+	backoff := maxWebhookDelay
+	// the delay is compared before it is shifted since a large shift overflows to a zero or negative delay
+	if attempt < 16 && delay < maxWebhookDelay>>attempt {
+		backoff = delay << attempt
+	}
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// postWebhook executes a single webhook request and returns if the request can be retried when an error occurs
+func postWebhook(ctx context.Context, client *http.Client, body []byte, opts WebhookOptions) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range opts.Headers {
+		req.Header.Set(k, v)
+	}
+	if opts.HMACSecret != "" {
+		req.Header.Set(WebhookSignatureHeader, webhookSignaturePrefix+sign(body, opts.HMACSecret))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return !errors.Is(err, context.Canceled), err
+	}
+	defer resp.Body.Close()
+	// drain the body so the connection can be reused on retries
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("webhook %s responded with status %s", opts.URL, resp.Status)
+}
+
+// sign returns the hex encoded HMAC-SHA256 of the body
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
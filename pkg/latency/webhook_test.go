@@ -0,0 +1,119 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package latency
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// webhookServer responds with the statuses in order, repeating the last status, and counts the requests
+func webhookServer(t *testing.T, statuses ...int) (*httptest.Server, *int32) {
+	t.Helper()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1))
+		w.WriteHeader(statuses[min(n, len(statuses))-1])
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestPostWebhookRetriesOn5xx(t *testing.T) {
+	server, requests := webhookServer(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+	err := (&Measurement{}).PostWebhook(context.Background(), WebhookOptions{URL: server.URL, Retries: 3, RetryDelay: time.Millisecond})
+	if err != nil {
+		t.Fatalf("expected the webhook to succeed after retries, got %v", err)
+	}
+	if got := atomic.LoadInt32(requests); got != 3 {
+		t.Errorf("expected 3 requests, got %d", got)
+	}
+}
+
+func TestPostWebhookGivesUpAfterRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		retries  int
+		status   int
+		expected int32
+	}{
+		{name: "retries exhausted", retries: 2, status: http.StatusServiceUnavailable, expected: 3},
+		{name: "retries disabled", retries: 0, status: http.StatusServiceUnavailable, expected: 1},
+		{name: "client error is not retried", retries: 2, status: http.StatusBadRequest, expected: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server, requests := webhookServer(t, tc.status)
+			err := (&Measurement{}).PostWebhook(context.Background(), WebhookOptions{URL: server.URL, Retries: tc.retries, RetryDelay: time.Millisecond})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := atomic.LoadInt32(requests); got != tc.expected {
+				t.Errorf("expected %d requests, got %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestPostWebhookSignature(t *testing.T) {
+	secret := "s3cr3t"
+	var signature string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(WebhookSignatureHeader)
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	measurement := &Measurement{Metadata: &Metadata{Region: "us-west-2", InstanceID: "i-0123456789abcdef0"}}
+	if err := measurement.PostWebhook(context.Background(), WebhookOptions{URL: server.URL, HMACSecret: secret}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if expected := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != expected {
+		t.Errorf("expected signature %s, got %s", expected, signature)
+	}
+}
+
+func TestWebhookBackoffIsBounded(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		delay    time.Duration
+		attempt  int
+		expected time.Duration
+	}{
+		{name: "first retry", delay: time.Second, attempt: 0, expected: time.Second},
+		{name: "doubles", delay: time.Second, attempt: 3, expected: 8 * time.Second},
+		{name: "capped", delay: time.Second, attempt: 10, expected: maxWebhookDelay},
+		{name: "shift overflow", delay: time.Second, attempt: 64, expected: maxWebhookDelay},
+		{name: "delay overflow", delay: time.Duration(1) << 62, attempt: 2, expected: maxWebhookDelay},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for range 100 {
+				if backoff := webhookBackoff(tc.delay, tc.attempt); backoff < tc.expected/2 || backoff > tc.expected {
+					t.Fatalf("expected a backoff between %s and %s, got %s", tc.expected/2, tc.expected, backoff)
+				}
+			}
+		})
+	}
+}
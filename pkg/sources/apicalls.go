@@ -0,0 +1,52 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources
+
+import (
+	"sync"
+)
+
+// APICallCounter is an optional interface implemented by sources that call remote APIs so that API usage can be monitored
+type APICallCounter interface {
+	// APICalls returns the number of API calls made by the source keyed by operation
+	APICalls() map[string]int64
+}
+
+// APICalls is a concurrency safe count of API calls by operation, the zero value is ready to use
+type APICalls struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// Inc increments the count of calls to the operation
+func (a *APICalls) Inc(operation string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.counts == nil {
+		a.counts = map[string]int64{}
+	}
+	a.counts[operation]++
+}
+
+// Counts returns a copy of the call counts by operation
+func (a *APICalls) Counts() map[string]int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	counts := make(map[string]int64, len(a.counts))
+	for operation, count := range a.counts {
+		counts[operation] = count
+	}
+	return counts
+}
@@ -0,0 +1,225 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+)
+
+var (
+	// ProcStatPath is the path to the kernel's stat file which contains the boot time
+	ProcStatPath = "/proc/stat"
+)
+
+// BootTime returns the time the current boot started using the btime field in /proc/stat
+func BootTime() (time.Time, error) {
+	file, err := os.Open(ProcStatPath)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to open %s to find the boot time: %w", ProcStatPath, err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] != "btime" {
+			continue
+		}
+		btime, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to parse boot time \"%s\": %w", fields[1], err)
+		}
+		return time.Unix(btime, 0).UTC(), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, fmt.Errorf("unable to read %s: %w", ProcStatPath, err)
+	}
+	return time.Time{}, fmt.Errorf("unable to find the boot time in %s", ProcStatPath)
+}
+
+const (
+	// bootTimeTolerance allows for clock adjustments between the kernel boot time and the first logged line of a boot
+	bootTimeTolerance = time.Minute
+	bootIDGroup       = "id"
+)
+
+var (
+	// BootIDPath is the path to the kernel's random boot ID which is also the journald _BOOT_ID
+	BootIDPath = "/proc/sys/kernel/random/boot_id"
+)
+
+// Boot is a single boot of the node detected from boot boundaries within a source
+type Boot struct {
+	// Index is the 1-indexed position of the boot in the source from oldest to newest
+	Index int `json:"index"`
+	// ID is the boot ID if the source records it (i.e. journald's _BOOT_ID)
+	ID    string    `json:"id,omitempty"`
+	Start time.Time `json:"start"`
+	// End is the start of the next boot or nil for the newest boot
+	End *time.Time `json:"end,omitempty"`
+	// Current is true if this is the boot the node is currently running
+	Current bool `json:"current"`
+}
+
+// Contains returns true if the time is within the boot
+func (b *Boot) Contains(t time.Time) bool {
+	return !t.Before(b.Start) && (b.End == nil || t.Before(*b.End))
+}
+
+// BootScoper is an optional interface implemented by sources that can detect boots and restrict their data to a single boot
+type BootScoper interface {
+	// Boots returns the boots found in the source from oldest to newest
+	Boots() ([]*Boot, error)
+	// ScopeToBoot restricts the source to data from the boot, a nil boot removes the restriction
+	ScopeToBoot(boot *Boot)
+}
+
+// BootID returns the current boot ID without dashes so that it can be compared to journald's _BOOT_ID
+func BootID() (string, error) {
+	bootID, err := os.ReadFile(BootIDPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the boot ID from %s: %w", BootIDPath, err)
+	}
+	return normalizeBootID(string(bootID)), nil
+}
+
+// normalizeBootID lower cases and removes dashes and whitespace from a boot ID
+func normalizeBootID(bootID string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(bootID), "-", ""))
+}
+
+// Boots returns the boots found in the log using the BootRegex from oldest to newest.
+// A match without an id directly following a match with an id (i.e. the kernel line after a journald boot marker) belongs to the same boot.
+// The files are scanned a line at a time so that the logs of every boot are not held in memory.
+func (l *LogReader) Boots() ([]*Boot, error) {
+	if l.BootRegex == nil {
+		return nil, nil
+	}
+	paths, err := l.resolve()
+	if err != nil {
+		return nil, err
+	}
+	var boots []*Boot
+	var errs error
+	idIndex := l.BootRegex.SubexpIndex(bootIDGroup)
+	pendingID := false
+	// boot markers may not have a timestamp so the start is the first timestamp at or after the marker
+	var pendingStart *Boot
+	read := 0
+	for _, path := range paths {
+		err := l.eachLine(path, func(line []byte) {
+			if match := l.BootRegex.FindSubmatchIndex(line); match != nil {
+				id := ""
+				if idIndex != -1 && match[2*idIndex] != -1 {
+					id = normalizeBootID(string(line[match[2*idIndex]:match[2*idIndex+1]]))
+				}
+				switch {
+				case id == "" && pendingID:
+					pendingID = false
+				// each journal file of a boot starts with the same boot marker
+				case id != "" && len(boots) != 0 && boots[len(boots)-1].ID == id:
+				default:
+					pendingID = id != ""
+					pendingStart = &Boot{Index: len(boots) + 1, ID: id}
+					boots = append(boots, pendingStart)
+				}
+			}
+			if pendingStart == nil {
+				return
+			}
+			if ts, err := l.ParseTimestamp(string(line)); err == nil {
+				pendingStart.Start = ts
+				pendingStart = nil
+			}
+		})
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		read++
+	}
+	// rotated files that cannot be read are skipped as long as at least one file could be read
+	if read == 0 {
+		return nil, errs
+	}
+	return CompleteBoots(boots), nil
+}
+
+// CompleteBoots sets the end of each boot to the start of the next boot and marks the current boot, which is identified by its ID
+// or by starting after the kernel boot time. If the current boot has not been logged yet, a boot starting at the kernel boot time
+// is appended so that stale boots are never considered current.
+func CompleteBoots(boots []*Boot) []*Boot {
+	for i, boot := range boots[:max(len(boots)-1, 0)] {
+		boot.End = &boots[i+1].Start
+	}
+	markCurrentBoot(boots)
+	if !lo.ContainsBy(boots, func(b *Boot) bool { return b.Current }) {
+		if bootTime, err := BootTime(); err == nil {
+			if len(boots) != 0 {
+				boots[len(boots)-1].End = &bootTime
+			}
+			boots = append(boots, &Boot{Index: len(boots) + 1, Start: bootTime, Current: true})
+		}
+	}
+	return boots
+}
+
+// markCurrentBoot marks the boot matching the current boot ID, or the newest boot if it started after the kernel boot time.
+// If neither the boot ID nor the boot time are available, the newest boot is assumed to be current.
+func markCurrentBoot(boots []*Boot) {
+	if len(boots) == 0 {
+		return
+	}
+	if bootID, err := BootID(); err == nil {
+		if boot, ok := lo.Find(boots, func(b *Boot) bool { return b.ID == bootID }); ok {
+			boot.Current = true
+			return
+		}
+	}
+	newest := boots[len(boots)-1]
+	bootTime, err := BootTime()
+	if err != nil || !newest.Start.Before(bootTime.Add(-bootTimeTolerance)) {
+		newest.Current = true
+	}
+}
+
+// Equal returns true if both boots are nil or have the same start and end
+func (b *Boot) Equal(other *Boot) bool {
+	if b == nil || other == nil {
+		return b == other
+	}
+	return b.Start.Equal(other.Start) && lo.FromPtr(b.End).Equal(lo.FromPtr(other.End))
+}
+
+// Boot returns the boot the LogReader is scoped to or nil if it is not scoped
+func (l *LogReader) Boot() *Boot {
+	return l.boot
+}
+
+// ScopeToBoot restricts matches to lines logged during the boot, a nil boot removes the restriction.
+// The cached log is cleared when the boot changes since only the files modified during the boot are read.
+func (l *LogReader) ScopeToBoot(boot *Boot) {
+	if !l.boot.Equal(boot) {
+		l.ClearCache()
+	}
+	l.boot = boot
+}
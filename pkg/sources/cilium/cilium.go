@@ -0,0 +1,37 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cilium is a latency timing source for the Cilium agent pod logs
+package cilium
+
+import (
+	"regexp"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+var (
+	Name            = "cilium-agent"
+	DefaultPath     = "/var/log/pods/kube-system_cilium-*/cilium-agent/*.log*"
+	TimestampFormat = regexp.MustCompile(`[0-9]{4}\-[0-9]{2}\-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}\.[0-9]+Z`)
+	TimestampLayout = "2006-01-02T15:04:05.999999999Z"
+)
+
+// Source is the cilium-agent log source, it is an alias of sources.LogSource
+type Source = sources.LogSource
+
+// New instantiates a new instance of the cilium-agent source
+func New(path string) *Source {
+	return sources.NewLogSource(Name, path, TimestampFormat, TimestampLayout)
+}
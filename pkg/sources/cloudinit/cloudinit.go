@@ -0,0 +1,43 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cloudinit is a latency timing source for /var/log/cloud-init.log
+package cloudinit
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+var (
+	Name            = "Cloud-Init"
+	DefaultPath     = "/var/log/cloud-init.log*"
+	TimestampFormat = regexp.MustCompile(`[0-9]{4}-[0-9]{2}-[0-9]{2} [0-9]{2}:[0-9]{2}:[0-9]{2},[0-9]{3}`)
+	TimestampLayout = "2006-01-02 15:04:05,000"
+	// BootRegex matches the first cloud-init stage of every boot
+	BootRegex = regexp.MustCompile(`Cloud-init v\. \S+ running 'init-local'`)
+)
+
+// Source is the /var/log/cloud-init.log log source, it is an alias of sources.LogSource
+type Source = sources.LogSource
+
+// New instantiates a new instance of cloud-init source
+func New(path string) *Source {
+	return sources.NewLogSource(Name, path, TimestampFormat, TimestampLayout).
+		WithBootRegex(BootRegex).
+		// cloud-init logs timestamps without a zone in the host's time zone
+		WithLocation(time.Local)
+}
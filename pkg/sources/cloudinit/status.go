@@ -0,0 +1,203 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudinit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+var (
+	StatusName        = "Cloud-Init Status"
+	DefaultStatusPath = "/run/cloud-init/status.json"
+	DefaultResultPath = "/run/cloud-init/result.json"
+	// Stages are the cloud-init stages recorded in status.json in the order they run
+	Stages = []string{"init-local", "init", "modules-config", "modules-final"}
+)
+
+// StatusSource is the source of the cloud-init stage timings in status.json and the result in result.json which are written to /run on every boot
+type StatusSource struct {
+	statusPath string
+	resultPath string
+}
+
+// StageTime is the start or finish of a cloud-init stage, or the time the result was written
+type StageTime struct {
+	Stage      string    `json:"stage,omitempty"`
+	Time       time.Time `json:"time"`
+	Datasource string    `json:"datasource,omitempty"`
+	Errors     []string  `json:"errors,omitempty"`
+}
+
+// stageStatus is a stage of status.json, the times are unix seconds which are null until the stage starts or finishes
+type stageStatus struct {
+	Start    *float64 `json:"start"`
+	Finished *float64 `json:"finished"`
+}
+
+// NewStatus instantiates a new instance of the cloud-init status source
+func NewStatus(statusPath string, resultPath string) *StatusSource {
+	return &StatusSource{statusPath: statusPath, resultPath: resultPath}
+}
+
+// ClearCache is a noop for the cloud-init status source since the files are small and read on every find
+func (s StatusSource) ClearCache() {}
+
+// String is a human readable string of the source
+func (s StatusSource) String() string {
+	return fmt.Sprintf("%s, %s", s.statusPath, s.resultPath)
+}
+
+// Name is the name of the source
+func (s StatusSource) Name() string {
+	return StatusName
+}
+
+// FindStageStarts is a helper func that returns a FindFunc to find the start of each cloud-init stage
+func (s StatusSource) FindStageStarts() sources.FindFunc {
+	return func(_ context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		return s.stageTimes(func(status stageStatus) *float64 { return status.Start })
+	}
+}
+
+// FindStageFinishes is a helper func that returns a FindFunc to find the finish of each cloud-init stage
+func (s StatusSource) FindStageFinishes() sources.FindFunc {
+	return func(_ context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		return s.stageTimes(func(status stageStatus) *float64 { return status.Finished })
+	}
+}
+
+// FindResult is a helper func that returns a FindFunc to find when cloud-init wrote result.json after the final stage
+func (s StatusSource) FindResult() sources.FindFunc {
+	return func(_ context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		info, err := os.Stat(s.resultPath)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, sources.Errorf(sources.ErrorCategoryNoMatch, "cloud-init has not finished, %s does not exist", s.resultPath)
+		}
+		if err != nil {
+			return nil, sources.Errorf(sources.ErrorCategorySourceUnavailable, "unable to stat %s: %w", s.resultPath, err)
+		}
+		var result struct {
+			V1 StageTime `json:"v1"`
+		}
+		if err := s.readJSON(s.resultPath, &result); err != nil {
+			return nil, err
+		}
+		result.V1.Time = info.ModTime()
+		value, err := json.Marshal(result.V1)
+		if err != nil {
+			return nil, err
+		}
+		return []string{string(value)}, nil
+	}
+}
+
+// stageTimes returns the StageTime of each stage that has the start or finish time selected by timeFn
+func (s StatusSource) stageTimes(timeFn func(stageStatus) *float64) ([]string, error) {
+	var status struct {
+		V1 map[string]json.RawMessage `json:"v1"`
+	}
+	if err := s.readJSON(s.statusPath, &status); err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, stage := range Stages {
+		var stageStatus stageStatus
+		if raw, ok := status.V1[stage]; !ok || json.Unmarshal(raw, &stageStatus) != nil {
+			continue
+		}
+		seconds := timeFn(stageStatus)
+		if seconds == nil {
+			continue
+		}
+		value, err := json.Marshal(StageTime{Stage: stage, Time: time.UnixMicro(int64(*seconds * 1e6)).UTC()})
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, string(value))
+	}
+	if len(values) == 0 {
+		return nil, sources.Errorf(sources.ErrorCategoryNoMatch, "no stage timings in %s", s.statusPath)
+	}
+	return values, nil
+}
+
+// readJSON unmarshals a cloud-init json file
+func (s StatusSource) readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return sources.Errorf(sources.ErrorCategorySourceUnavailable, "cloud-init has not run this boot, %s does not exist", path)
+	}
+	if err != nil {
+		return sources.Errorf(sources.ErrorCategorySourceUnavailable, "unable to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// Find will use the Event's FindFunc and CommentFunc to search the cloud-init status and return the results based on the Event's matcher
+func (s StatusSource) Find(ctx context.Context, event *sources.Event) ([]sources.FindResult, error) {
+	values, err := event.FindFn(ctx, s, nil)
+	if err != nil {
+		return nil, err
+	}
+	var results []sources.FindResult
+	for _, value := range values {
+		var stageTime StageTime
+		if err := json.Unmarshal([]byte(value), &stageTime); err != nil {
+			return nil, sources.NewError(sources.ErrorCategoryTimestampParse, err)
+		}
+		comment := ""
+		if event.CommentFn != nil {
+			comment = event.CommentFn(value)
+		}
+		results = append(results, sources.FindResult{
+			Line:      value,
+			Timestamp: stageTime.Time,
+			Comment:   comment,
+			File:      lo.Ternary(stageTime.Stage == "", s.resultPath, s.statusPath),
+		})
+	}
+	return sources.SelectMatches(results, event.MatchSelector), nil
+}
+
+// CommentValue is a CommentFunc which comments the stage of a stage timing, or the datasource and errors of the result
+func CommentValue() sources.CommentFunc {
+	return func(matchedLine string) string {
+		var stageTime StageTime
+		if err := json.Unmarshal([]byte(matchedLine), &stageTime); err != nil {
+			return ""
+		}
+		if stageTime.Stage != "" {
+			return stageTime.Stage
+		}
+		comment := stageTime.Datasource
+		if len(stageTime.Errors) != 0 {
+			comment = fmt.Sprintf("%s errors: %s", comment, strings.Join(stageTime.Errors, "; "))
+		}
+		return strings.TrimSpace(comment)
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"regexp"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	asgtypes "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/samber/lo"
@@ -34,25 +36,44 @@ var (
 	instanceIDRegex = regexp.MustCompile(`i-[0-9a-zA-Z]+`)
 )
 
-// Source is the EC2 Instance Metadata Service (IMDS) http source
+// Source is the EC2 and Auto Scaling API http source
 type Source struct {
-	ec2Client  *ec2.Client
-	instanceID string
-	fleetID    string
-	nodeName   string
+	ec2Client   *ec2.Client
+	asgClient   *autoscaling.Client
+	instanceID  string
+	fleetID     string
+	nodeName    string
+	apiCalls    *sources.APICalls
+	tags        map[string]string
+	fleet       *types.FleetData
+	asgActivity *asgtypes.Activity
+	instance    *types.Instance
+	// describes and describedAt schedule the refreshes of the instance since its ENIs and volumes are attached over time
+	describes   int
+	describedAt time.Time
+	now         func() time.Time
 }
 
-// New instantiates a new instance of the EC2 API source
-func New(ec2Client *ec2.Client, instanceID string, nodeName string) *Source {
+// New instantiates a new instance of the EC2 API source, the Auto Scaling client is optional and only used for instances launched by an ASG
+func New(ec2Client *ec2.Client, asgClient *autoscaling.Client, instanceID string, nodeName string) *Source {
 	return &Source{
 		ec2Client:  ec2Client,
+		asgClient:  asgClient,
 		instanceID: instanceID,
 		nodeName:   nodeName,
+		apiCalls:   &sources.APICalls{},
+		now:        time.Now,
 	}
 }
 
-// ClearCache is a noop for the EC2 Source since it is an http source, not a log file
-func (s Source) ClearCache() {}
+// ClearCache keeps the cached instance, it is described again on a backoff schedule rather than on every retry so that the all-match
+// ENI and EBS attach events, which are never resolved, do not call DescribeInstances on every retry
+func (s *Source) ClearCache() {}
+
+// APICalls returns the number of EC2 API calls made by the source keyed by operation
+func (s Source) APICalls() map[string]int64 {
+	return s.apiCalls.Counts()
+}
 
 // String is a human readable string of the source
 func (s Source) String() string {
@@ -64,33 +85,46 @@ func (s Source) Name() string {
 	return Name
 }
 
-// FindFleetStart retrieves the Fleet request start time
+// FindFleetStart retrieves the Fleet request start time, instances which were not launched by an EC2 Fleet have no results
 func (s *Source) FindFleetStart() sources.FindFunc {
-	return func(_ sources.Source, _ []byte) ([]string, error) {
-		ctx := context.Background()
-		var err error
-		s.instanceID, err = s.getInstanceID(ctx)
-		if err != nil {
-			return nil, err
-		}
-		s.fleetID, err = s.getFleetID(ctx)
-		if err != nil {
-			return nil, err
-		}
-		fleetsOut, err := s.ec2Client.DescribeFleets(ctx, &ec2.DescribeFleetsInput{
-			FleetIds: []string{s.fleetID},
-		})
-		if err != nil {
+	return func(ctx context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		fleet, err := s.getFleet(ctx)
+		if err != nil || fleet == nil {
 			return nil, err
 		}
-		if len(fleetsOut.Fleets) != 1 {
-			return nil, fmt.Errorf("no fleet found for %s and fleet-id %s", s.instanceID, s.fleetID)
-		}
-		fleetBytes, err := json.Marshal(fleetsOut.Fleets[0])
+		fleetBytes, err := json.Marshal(fleet)
 		return []string{string(fleetBytes)}, err
 	}
 }
 
+// getFleet retrieves the EC2 Fleet which launched the instance or nil if the instance has no fleet tag
+func (s *Source) getFleet(ctx context.Context) (*types.FleetData, error) {
+	if s.fleet != nil {
+		return s.fleet, nil
+	}
+	var err error
+	s.instanceID, err = s.getInstanceID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.fleetID, err = s.getFleetID(ctx)
+	if err != nil || s.fleetID == "" {
+		return nil, err
+	}
+	s.apiCalls.Inc("DescribeFleets")
+	fleetsOut, err := s.ec2Client.DescribeFleets(ctx, &ec2.DescribeFleetsInput{
+		FleetIds: []string{s.fleetID},
+	})
+	if err != nil {
+		return nil, sources.NewError(sources.ErrorCategoryAPI, err)
+	}
+	if len(fleetsOut.Fleets) != 1 {
+		return nil, sources.Errorf(sources.ErrorCategoryNoMatch, "no fleet found for %s and fleet-id %s", s.instanceID, s.fleetID)
+	}
+	s.fleet = &fleetsOut.Fleets[0]
+	return s.fleet, nil
+}
+
 // getInstanceID retrieves the instance-id from cached values, node name, or DescribeInstances filtered by dns name
 func (s Source) getInstanceID(ctx context.Context) (string, error) {
 	if s.instanceID != "" {
@@ -100,6 +134,7 @@ func (s Source) getInstanceID(ctx context.Context) (string, error) {
 		if instanceID := instanceIDRegex.FindString(s.nodeName); instanceID != "" {
 			return instanceID, nil
 		}
+		s.apiCalls.Inc("DescribeInstances")
 		instancesOut, err := s.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
 			Filters: []types.Filter{
 				{
@@ -113,21 +148,35 @@ func (s Source) getInstanceID(ctx context.Context) (string, error) {
 			},
 		})
 		if err != nil {
-			return "", err
+			return "", sources.NewError(sources.ErrorCategoryAPI, err)
 		}
-		if len(instancesOut.Reservations) != 1 && len(instancesOut.Reservations[0].Instances) != 1 {
-			return "", fmt.Errorf("unable to discover instance-id from node-name: %s", s.nodeName)
+		instances := lo.FlatMap(instancesOut.Reservations, func(r types.Reservation, _ int) []types.Instance { return r.Instances })
+		if len(instances) != 1 || lo.FromPtr(instances[0].InstanceId) == "" {
+			return "", sources.Errorf(sources.ErrorCategoryMissingPrerequisite, "unable to discover instance-id from node-name: %s", s.nodeName)
 		}
-		return *instancesOut.Reservations[0].Instances[0].InstanceId, nil
+		return *instances[0].InstanceId, nil
 	}
-	return "", fmt.Errorf("unable to get instance ID")
+	return "", sources.Errorf(sources.ErrorCategoryMissingPrerequisite, "unable to get instance ID")
 }
 
-// getFleetID retrieves the fleet-id using the instance-id to query for the aws fleet system tag
-func (s Source) getFleetID(ctx context.Context) (string, error) {
+// getFleetID retrieves the fleet-id from the aws fleet system tag or an empty string if the instance was not launched by an EC2 Fleet
+func (s *Source) getFleetID(ctx context.Context) (string, error) {
 	if s.fleetID != "" {
 		return s.fleetID, nil
 	}
+	tags, err := s.getTags(ctx)
+	if err != nil {
+		return "", err
+	}
+	return tags[fleetIDTag], nil
+}
+
+// getTags retrieves and caches the tags of the instance
+func (s *Source) getTags(ctx context.Context) (map[string]string, error) {
+	if s.tags != nil {
+		return s.tags, nil
+	}
+	s.apiCalls.Inc("DescribeTags")
 	tagsOut, err := s.ec2Client.DescribeTags(ctx, &ec2.DescribeTagsInput{
 		Filters: []types.Filter{
 			{
@@ -141,28 +190,49 @@ func (s Source) getFleetID(ctx context.Context) (string, error) {
 		},
 	})
 	if err != nil {
-		return "", err
+		return nil, sources.NewError(sources.ErrorCategoryAPI, err)
 	}
-	fleetTag, ok := lo.Find(tagsOut.Tags, func(t types.TagDescription) bool {
-		return *t.Key == "aws:ec2:fleet-id"
+	s.tags = lo.SliceToMap(tagsOut.Tags, func(t types.TagDescription) (string, string) {
+		return lo.FromPtr(t.Key), lo.FromPtr(t.Value)
 	})
-	if !ok {
-		return "", fmt.Errorf("unable to find fleet tag for %s", s.instanceID)
+	return s.tags, nil
+}
+
+// Explain describes the missing instance-id or Auto Scaling client for an event that could not be measured
+func (s *Source) Explain(_ *sources.Event, err error) *sources.Explanation {
+	explanation := &sources.Explanation{}
+	if sources.ErrorCategory(err) != sources.ErrorCategoryMissingPrerequisite {
+		return explanation
+	}
+	if s.instanceID == "" {
+		explanation.MissingPrerequisites = append(explanation.MissingPrerequisites,
+			fmt.Sprintf("instance-id: unable to discover the instance-id from EC2 IMDS or the node name %q", s.nodeName))
+	} else if s.tags[autoScalingGroupTag] != "" && s.asgClient == nil {
+		explanation.MissingPrerequisites = append(explanation.MissingPrerequisites,
+			fmt.Sprintf("Auto Scaling client: instance %s was launched by ASG %s but the Auto Scaling client is not configured", s.instanceID, s.tags[autoScalingGroupTag]))
 	}
-	return *fleetTag.Value, nil
+	return explanation
 }
 
+// ParseTimeFor parses an EC2 Fleet, a Launch, or an Attachment and returns the time
 func (s *Source) ParseTimeFor(event []byte) (time.Time, error) {
 	var fleetData *types.FleetData
 	if err := json.Unmarshal(event, &fleetData); err == nil && fleetData.CreateTime != nil {
 		return *fleetData.CreateTime, nil
 	}
-	return time.Time{}, fmt.Errorf("unable to parse event")
+	// Launches and Attachments share the time field
+	var timed struct {
+		Time time.Time `json:"time"`
+	}
+	if err := json.Unmarshal(event, &timed); err == nil && !timed.Time.IsZero() {
+		return timed.Time, nil
+	}
+	return time.Time{}, sources.Errorf(sources.ErrorCategoryTimestampParse, "unable to parse event")
 }
 
 // Find will use the Event's FindFunc and CommentFunc to search the source and return the result
-func (s *Source) Find(event *sources.Event) ([]sources.FindResult, error) {
-	ec2Events, err := event.FindFn(s, nil)
+func (s *Source) Find(ctx context.Context, event *sources.Event) ([]sources.FindResult, error) {
+	ec2Events, err := event.FindFn(ctx, s, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -180,5 +250,5 @@ func (s *Source) Find(event *sources.Event) ([]sources.FindResult, error) {
 			Err:       err,
 		})
 	}
-	return results, nil
+	return sources.SelectMatches(results, event.MatchSelector), nil
 }
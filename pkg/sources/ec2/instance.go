@@ -0,0 +1,199 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ec2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+// Attachment type consts
+const (
+	AttachmentTypeENI = "eni"
+	AttachmentTypeEBS = "ebs"
+)
+
+// vpcCNIENIDescriptionPrefix is the description prefix of ENIs created by the VPC CNI
+const vpcCNIENIDescriptionPrefix = "aws-K8S-"
+
+const (
+	// instanceRefreshBase is the delay before the instance is first described again
+	instanceRefreshBase = 5 * time.Second
+	// instanceRefreshMax caps the exponentially increasing delay between describes of the instance
+	instanceRefreshMax = 2 * time.Minute
+)
+
+// Attachment is the attachment of an ENI or EBS volume to the instance
+type Attachment struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	// Device is the device index of an ENI or the device name of an EBS volume
+	Device string    `json:"device"`
+	Time   time.Time `json:"time"`
+}
+
+// FindLaunchTime retrieves the launch time of the instance
+func (s *Source) FindLaunchTime() sources.FindFunc {
+	return func(ctx context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		launchTime, err := s.getLaunchTime(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return marshalLaunch(Launch{Time: launchTime})
+	}
+}
+
+// FindPrimaryENIAttach retrieves the attach time of the instance's primary ENI
+func (s *Source) FindPrimaryENIAttach() sources.FindFunc {
+	return func(ctx context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		instance, err := s.getInstance(ctx)
+		if err != nil {
+			return nil, err
+		}
+		eni, ok := lo.Find(instance.NetworkInterfaces, func(eni types.InstanceNetworkInterface) bool {
+			return eni.Attachment != nil && lo.FromPtr(eni.Attachment.DeviceIndex) == 0 && lo.FromPtr(eni.Attachment.NetworkCardIndex) == 0
+		})
+		if !ok {
+			return nil, sources.Errorf(sources.ErrorCategoryNoMatch, "no primary ENI found for %s", s.instanceID)
+		}
+		return marshalAttachments(eniAttachment(eni))
+	}
+}
+
+// FindVPCCNIENIAttach retrieves the attach times of the secondary ENIs the VPC CNI created for pod IPs
+func (s *Source) FindVPCCNIENIAttach() sources.FindFunc {
+	return func(ctx context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		instance, err := s.getInstance(ctx)
+		if err != nil {
+			return nil, err
+		}
+		enis := lo.Filter(instance.NetworkInterfaces, func(eni types.InstanceNetworkInterface, _ int) bool {
+			return eni.Attachment != nil && eni.Attachment.AttachTime != nil && strings.HasPrefix(lo.FromPtr(eni.Description), vpcCNIENIDescriptionPrefix)
+		})
+		return marshalAttachments(lo.Map(enis, func(eni types.InstanceNetworkInterface, _ int) Attachment { return eniAttachment(eni) })...)
+	}
+}
+
+// FindEBSAttach retrieves the attach times of the EBS volumes in the instance's block device mappings
+func (s *Source) FindEBSAttach() sources.FindFunc {
+	return func(ctx context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		instance, err := s.getInstance(ctx)
+		if err != nil {
+			return nil, err
+		}
+		mappings := lo.Filter(instance.BlockDeviceMappings, func(bdm types.InstanceBlockDeviceMapping, _ int) bool {
+			return bdm.Ebs != nil && bdm.Ebs.AttachTime != nil
+		})
+		return marshalAttachments(lo.Map(mappings, func(bdm types.InstanceBlockDeviceMapping, _ int) Attachment {
+			return Attachment{
+				Type:   AttachmentTypeEBS,
+				ID:     lo.FromPtr(bdm.Ebs.VolumeId),
+				Device: lo.FromPtr(bdm.DeviceName),
+				Time:   *bdm.Ebs.AttachTime,
+			}
+		})...)
+	}
+}
+
+// CommentAttachment is a CommentFunc which comments the attached ENI or EBS volume and its device
+func CommentAttachment() sources.CommentFunc {
+	return func(matchedLine string) string {
+		var attachment Attachment
+		if err := json.Unmarshal([]byte(matchedLine), &attachment); err != nil || attachment.ID == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s (%s)", attachment.ID, attachment.Device)
+	}
+}
+
+// getInstance retrieves the instance, which is described again with an exponentially increasing delay (5s doubling up to 2m)
+// since ENIs and volumes are attached over time
+func (s *Source) getInstance(ctx context.Context) (*types.Instance, error) {
+	if s.instance != nil && s.now().Before(s.describedAt.Add(s.refreshDelay())) {
+		return s.instance, nil
+	}
+	var err error
+	s.instanceID, err = s.getInstanceID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.apiCalls.Inc("DescribeInstances")
+	out, err := s.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []string{s.instanceID},
+	})
+	if err != nil {
+		return nil, sources.NewError(sources.ErrorCategoryAPI, err)
+	}
+	instances := lo.FlatMap(out.Reservations, func(r types.Reservation, _ int) []types.Instance { return r.Instances })
+	if len(instances) != 1 {
+		return nil, sources.Errorf(sources.ErrorCategoryNoMatch, "instance %s not found", s.instanceID)
+	}
+	s.instance = &instances[0]
+	s.describes++
+	s.describedAt = s.now()
+	return s.instance, nil
+}
+
+// refreshDelay returns the delay after the last describe before the instance is described again
+func (s *Source) refreshDelay() time.Duration {
+	if shift := s.describes - 1; shift < 16 {
+		return min(instanceRefreshBase<<shift, instanceRefreshMax)
+	}
+	return instanceRefreshMax
+}
+
+// getLaunchTime retrieves the launch time of the instance
+func (s *Source) getLaunchTime(ctx context.Context) (time.Time, error) {
+	instance, err := s.getInstance(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if instance.LaunchTime == nil {
+		return time.Time{}, sources.Errorf(sources.ErrorCategoryNoMatch, "no launch time found for %s", s.instanceID)
+	}
+	return *instance.LaunchTime, nil
+}
+
+// eniAttachment converts an instance network interface to an Attachment
+func eniAttachment(eni types.InstanceNetworkInterface) Attachment {
+	return Attachment{
+		Type:   AttachmentTypeENI,
+		ID:     lo.FromPtr(eni.NetworkInterfaceId),
+		Device: fmt.Sprintf("device %d", lo.FromPtr(eni.Attachment.DeviceIndex)),
+		Time:   lo.FromPtr(eni.Attachment.AttachTime),
+	}
+}
+
+// marshalAttachments returns the attachments as FindFunc results
+func marshalAttachments(attachments ...Attachment) ([]string, error) {
+	var results []string
+	for _, attachment := range attachments {
+		attachmentBytes, err := json.Marshal(attachment)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, string(attachmentBytes))
+	}
+	return results, nil
+}
@@ -0,0 +1,139 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ec2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+const describeInstancesResponse = `<DescribeInstancesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+  <reservationSet>
+    <item>
+      <instancesSet>
+        <item>
+          <instanceId>i-0123456789abcdef0</instanceId>
+          <launchTime>2024-01-01T00:00:00.000Z</launchTime>
+          <blockDeviceMapping>
+            <item>
+              <deviceName>/dev/xvda</deviceName>
+              <ebs>
+                <volumeId>vol-0123456789abcdef0</volumeId>
+                <attachTime>2024-01-01T00:00:01.000Z</attachTime>
+              </ebs>
+            </item>
+          </blockDeviceMapping>
+        </item>
+      </instancesSet>
+    </item>
+  </reservationSet>
+</DescribeInstancesResponse>`
+
+// ec2Server responds to every request with the DescribeInstances response and counts the requests
+func ec2Server(t *testing.T, response string) (*ec2.Client, *int32) {
+	t.Helper()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	client := ec2.New(ec2.Options{
+		Region:       "us-west-2",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+	return client, &requests
+}
+
+func TestGetInstanceIsNotDescribedOnEveryRetry(t *testing.T) {
+	client, requests := ec2Server(t, describeInstancesResponse)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := New(client, nil, "i-0123456789abcdef0", "")
+	s.now = func() time.Time { return now }
+	find := s.FindEBSAttach()
+	for _, tc := range []struct {
+		name      string
+		elapsed   time.Duration
+		describes int64
+	}{
+		{name: "first retrieval", elapsed: 0, describes: 1},
+		{name: "retry within the refresh delay", elapsed: time.Second, describes: 1},
+		{name: "retry after the first refresh delay", elapsed: instanceRefreshBase, describes: 2},
+		{name: "retry within the doubled refresh delay", elapsed: instanceRefreshBase, describes: 2},
+		{name: "retry after the doubled refresh delay", elapsed: instanceRefreshBase, describes: 3},
+		{name: "retry after the max refresh delay", elapsed: 10 * instanceRefreshMax, describes: 4},
+	} {
+		now = now.Add(tc.elapsed)
+		s.ClearCache()
+		results, err := find(context.Background(), s, nil)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("%s: expected 1 EBS attachment, got %d", tc.name, len(results))
+		}
+		if got := s.APICalls()["DescribeInstances"]; got != tc.describes {
+			t.Errorf("%s: expected %d DescribeInstances calls, got %d", tc.name, tc.describes, got)
+		}
+	}
+	if got := atomic.LoadInt32(requests); got != 4 {
+		t.Errorf("expected 4 requests, got %d", got)
+	}
+}
+
+func TestGetInstanceIDFromNodeName(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		response   string
+		instanceID string
+	}{
+		{name: "one instance", response: describeInstancesResponse, instanceID: "i-0123456789abcdef0"},
+		{name: "no reservations", response: `<DescribeInstancesResponse><reservationSet/></DescribeInstancesResponse>`},
+		{
+			name: "one instance per reservation",
+			response: `<DescribeInstancesResponse><reservationSet>
+  <item><instancesSet><item><instanceId>i-0123456789abcdef0</instanceId></item></instancesSet></item>
+  <item><instancesSet><item><instanceId>i-0123456789abcdef1</instanceId></item></instancesSet></item>
+</reservationSet></DescribeInstancesResponse>`,
+		},
+		{name: "no instance ID", response: `<DescribeInstancesResponse><reservationSet><item><instancesSet><item/></instancesSet></item></reservationSet></DescribeInstancesResponse>`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := ec2Server(t, tc.response)
+			instanceID, err := New(client, nil, "", "ip-192-168-0-1.us-west-2.compute.internal").getInstanceID(context.Background())
+			if tc.instanceID == "" {
+				if err == nil {
+					t.Fatalf("expected an error, got instance %s", instanceID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if instanceID != tc.instanceID {
+				t.Errorf("expected instance %s, got %s", tc.instanceID, instanceID)
+			}
+		})
+	}
+}
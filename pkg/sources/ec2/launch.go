@@ -0,0 +1,183 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ec2
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	asgtypes "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+// Launch method consts describing how an instance was launched
+const (
+	LaunchMethodFleet        = "fleet"
+	LaunchMethodAutoScaling  = "autoscaling"
+	LaunchMethodRunInstances = "run-instances"
+)
+
+// AWS system tags identifying how an instance was launched
+const (
+	fleetIDTag          = "aws:ec2:fleet-id"
+	autoScalingGroupTag = "aws:autoscaling:groupName"
+)
+
+// Launch is a launch event of the instance along with how the instance was launched
+type Launch struct {
+	Method           string    `json:"method,omitempty"`
+	Time             time.Time `json:"time"`
+	FleetID          string    `json:"fleetID,omitempty"`
+	AutoScalingGroup string    `json:"autoScalingGroup,omitempty"`
+	ActivityID       string    `json:"activityID,omitempty"`
+	Description      string    `json:"description,omitempty"`
+}
+
+// FindCapacityRequested retrieves when capacity was requested from the EC2 Fleet create time, the start of the Auto Scaling
+// activity which launched the instance, or the instance launch time for instances launched by RunInstances, in that order
+func (s *Source) FindCapacityRequested() sources.FindFunc {
+	return func(ctx context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		var err error
+		s.instanceID, err = s.getInstanceID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		fleet, err := s.getFleet(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if fleet != nil {
+			return marshalLaunch(Launch{Method: LaunchMethodFleet, Time: lo.FromPtr(fleet.CreateTime), FleetID: s.fleetID})
+		}
+		activity, err := s.getScalingActivity(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if activity != nil {
+			return marshalLaunch(scalingActivityLaunch(activity, lo.FromPtr(activity.StartTime)))
+		}
+		launchTime, err := s.getLaunchTime(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return marshalLaunch(Launch{Method: LaunchMethodRunInstances, Time: launchTime})
+	}
+}
+
+// FindLaunchSuccessful retrieves the end of the successful Auto Scaling activity which launched the instance,
+// instances which were not launched by an Auto Scaling group have no results
+func (s *Source) FindLaunchSuccessful() sources.FindFunc {
+	return func(ctx context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		var err error
+		s.instanceID, err = s.getInstanceID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		activity, err := s.getScalingActivity(ctx)
+		if err != nil || activity == nil {
+			return nil, err
+		}
+		if activity.StatusCode != asgtypes.ScalingActivityStatusCodeSuccessful || activity.EndTime == nil {
+			return nil, sources.Errorf(sources.ErrorCategoryNoMatch, "scaling activity %s for %s is %s", lo.FromPtr(activity.ActivityId), s.instanceID, activity.StatusCode)
+		}
+		return marshalLaunch(scalingActivityLaunch(activity, *activity.EndTime))
+	}
+}
+
+// AutoScalingGroupName returns the name of the Auto Scaling group which launched the instance or an empty string if it was not launched by an ASG
+func (s *Source) AutoScalingGroupName(ctx context.Context) (string, error) {
+	var err error
+	s.instanceID, err = s.getInstanceID(ctx)
+	if err != nil {
+		return "", err
+	}
+	tags, err := s.getTags(ctx)
+	if err != nil {
+		return "", err
+	}
+	return tags[autoScalingGroupTag], nil
+}
+
+// CommentLaunchMethod is a CommentFunc which comments how the instance was launched
+func CommentLaunchMethod() sources.CommentFunc {
+	return func(matchedLine string) string {
+		var launch Launch
+		if err := json.Unmarshal([]byte(matchedLine), &launch); err != nil || launch.Method == "" {
+			return ""
+		}
+		if launch.AutoScalingGroup != "" {
+			return launch.Method + ": " + launch.AutoScalingGroup
+		}
+		return launch.Method
+	}
+}
+
+// getScalingActivity retrieves the Auto Scaling activity which launched the instance or nil if the instance was not launched by an ASG.
+// The activity is cached once it is complete.
+func (s *Source) getScalingActivity(ctx context.Context) (*asgtypes.Activity, error) {
+	if s.asgActivity != nil {
+		return s.asgActivity, nil
+	}
+	asgName, err := s.AutoScalingGroupName(ctx)
+	if err != nil || asgName == "" {
+		return nil, err
+	}
+	if s.asgClient == nil {
+		return nil, sources.Errorf(sources.ErrorCategoryMissingPrerequisite, "instance %s was launched by ASG %s but the Auto Scaling client is not configured", s.instanceID, asgName)
+	}
+	paginator := autoscaling.NewDescribeScalingActivitiesPaginator(s.asgClient, &autoscaling.DescribeScalingActivitiesInput{
+		AutoScalingGroupName: &asgName,
+	})
+	for paginator.HasMorePages() {
+		s.apiCalls.Inc("DescribeScalingActivities")
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, sources.NewError(sources.ErrorCategoryAPI, err)
+		}
+		activity, ok := lo.Find(out.Activities, func(a asgtypes.Activity) bool {
+			return strings.Contains(lo.FromPtr(a.Description), s.instanceID) && strings.HasPrefix(lo.FromPtr(a.Description), "Launching")
+		})
+		if !ok {
+			continue
+		}
+		if activity.EndTime != nil {
+			s.asgActivity = &activity
+		}
+		return &activity, nil
+	}
+	return nil, sources.Errorf(sources.ErrorCategoryNoMatch, "no scaling activity found launching %s in ASG %s", s.instanceID, asgName)
+}
+
+// scalingActivityLaunch converts an Auto Scaling activity to a Launch at the time
+func scalingActivityLaunch(activity *asgtypes.Activity, t time.Time) Launch {
+	return Launch{
+		Method:           LaunchMethodAutoScaling,
+		Time:             t,
+		AutoScalingGroup: lo.FromPtr(activity.AutoScalingGroupName),
+		ActivityID:       lo.FromPtr(activity.ActivityId),
+		Description:      lo.FromPtr(activity.Description),
+	}
+}
+
+// marshalLaunch returns the Launch as a FindFunc result
+func marshalLaunch(launch Launch) ([]string, error) {
+	launchBytes, err := json.Marshal(launch)
+	return []string{string(launchBytes)}, err
+}
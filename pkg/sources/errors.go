@@ -0,0 +1,81 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Error Category consts which make timing errors machine readable
+const (
+	// ErrorCategorySourceUnavailable is used when a log file could not be found or read
+	ErrorCategorySourceUnavailable = "source_unavailable"
+	// ErrorCategoryNoMatch is used when a source was read successfully but did not contain the event
+	ErrorCategoryNoMatch = "no_match"
+	// ErrorCategoryTimestampParse is used when an event was found but its timestamp could not be parsed
+	ErrorCategoryTimestampParse = "timestamp_parse"
+	// ErrorCategoryMissingPrerequisite is used when data required to query a source (i.e. instance-id or node name) is unavailable
+	ErrorCategoryMissingPrerequisite = "missing_prerequisite"
+	// ErrorCategoryAPI is used when an API call to a source fails
+	ErrorCategoryAPI = "api_error"
+	// ErrorCategoryTimeout is used when a source did not respond within its timeout
+	ErrorCategoryTimeout = "timeout"
+	// ErrorCategoryUnknown is used for errors that have not been categorized
+	ErrorCategoryUnknown = "unknown"
+)
+
+// Error is an error with a machine readable category
+type Error struct {
+	Category string
+	Err      error
+	// Regex is the event regex that was being searched for when the error occurred, if any
+	Regex *regexp.Regexp
+	// Line is the matched line being processed when the error occurred, if any
+	Line string
+}
+
+// NewError wraps an error with a category
+func NewError(category string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Category: category, Err: err}
+}
+
+// Errorf formats an error with a category
+func Errorf(category string, format string, args ...any) error {
+	return &Error{Category: category, Err: fmt.Errorf(format, args...)}
+}
+
+// Error returns the underlying error message
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCategory returns the category of the first categorized error in the error's tree or ErrorCategoryUnknown
+func ErrorCategory(err error) string {
+	var categorizedErr *Error
+	if errors.As(err, &categorizedErr) {
+		return categorizedErr.Category
+	}
+	return ErrorCategoryUnknown
+}
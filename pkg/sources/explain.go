@@ -0,0 +1,226 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+)
+
+const (
+	maxNearestMatches    = 3
+	maxExplainLineLength = 256
+	minFuzzyTokenLength  = 3
+)
+
+// Explainer is an optional interface implemented by sources that can explain why an event could not be measured
+type Explainer interface {
+	// Explain returns diagnostics for an event that could not be measured along with the error that occurred, if any
+	Explain(event *Event, err error) *Explanation
+}
+
+// Explanation is the diagnostic information a source provides for an event that could not be measured
+type Explanation struct {
+	// Files are the log files resolved from the source's path
+	Files []LogFile `json:"files,omitempty"`
+	// NearestMatches are the log lines which most closely match the event's regex
+	NearestMatches []FuzzyMatch `json:"nearestMatches,omitempty"`
+	// TimestampRegex is the timestamp regex that failed to find or parse a timestamp on the TimestampLine
+	TimestampRegex string `json:"timestampRegex,omitempty"`
+	// TimestampLine is the matched line the timestamp could not be parsed from
+	TimestampLine string `json:"timestampLine,omitempty"`
+	// MissingPrerequisites are descriptions of data the source requires to find the event which are unavailable
+	MissingPrerequisites []string `json:"missingPrerequisites,omitempty"`
+}
+
+// LogFile describes a log file resolved from a LogReader's path
+type LogFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Resolved is true if the file is searched by the LogReader
+	Resolved       bool       `json:"resolved"`
+	FirstTimestamp *time.Time `json:"firstTimestamp,omitempty"`
+	LastTimestamp  *time.Time `json:"lastTimestamp,omitempty"`
+	Error          string     `json:"error,omitempty"`
+}
+
+// FuzzyMatch is a log line that partially matches the literal text of a regex
+type FuzzyMatch struct {
+	Line       string `json:"line"`
+	File       string `json:"file"`
+	LineNumber int    `json:"lineNumber"`
+	// Score is the fraction of the regex's literal text found in the line
+	Score float64 `json:"score"`
+}
+
+// Explain describes the files resolved from the LogReader's path, the lines nearest to the regex of a no_match error,
+// and the line a timestamp could not be parsed from for a timestamp_parse error
+func (l *LogReader) Explain(err error) *Explanation {
+	explanation := &Explanation{}
+	matches, globErr := l.globMatches()
+	if globErr != nil {
+		explanation.MissingPrerequisites = append(explanation.MissingPrerequisites, globErr.Error())
+		return explanation
+	}
+	paths, resolveErr := l.resolve()
+	if resolveErr != nil {
+		explanation.MissingPrerequisites = append(explanation.MissingPrerequisites, resolveErr.Error())
+	}
+	for _, match := range matches {
+		explanation.Files = append(explanation.Files, l.describeLogFile(match, lo.Contains(paths, match)))
+	}
+	var srcErr *Error
+	if !errors.As(err, &srcErr) {
+		return explanation
+	}
+	if srcErr.Regex != nil {
+		if log, err := l.Read(); err == nil {
+			explanation.NearestMatches = l.nearestMatches(log, srcErr.Regex)
+		}
+	}
+	if srcErr.Category == ErrorCategoryTimestampParse && srcErr.Line != "" {
+		explanation.TimestampRegex = l.TimestampRegex.String()
+		explanation.TimestampLine = truncateLine(srcErr.Line)
+	}
+	return explanation
+}
+
+// describeLogFile returns the size and the time range of a log file based on the first and last parsable timestamps
+func (l *LogReader) describeLogFile(path string, resolved bool) LogFile {
+	logFile := LogFile{Path: path, Resolved: resolved}
+	stat, err := os.Stat(path)
+	if err != nil {
+		logFile.Error = err.Error()
+		return logFile
+	}
+	logFile.Size = stat.Size()
+	log, err := l.readFile(path)
+	if err != nil {
+		logFile.Error = err.Error()
+		return logFile
+	}
+	lines := bytes.Split(log, []byte{'\n'})
+	for _, line := range lines {
+		if ts, err := l.ParseTimestamp(string(line)); err == nil {
+			logFile.FirstTimestamp = &ts
+			break
+		}
+	}
+	for i := len(lines) - 1; i >= 0; i-- {
+		if ts, err := l.ParseTimestamp(string(lines[i])); err == nil {
+			logFile.LastTimestamp = &ts
+			break
+		}
+	}
+	if logFile.FirstTimestamp == nil {
+		logFile.Error = "no lines with a parsable timestamp"
+	}
+	return logFile
+}
+
+// nearestMatches scores each line of the log by the fraction of the regex's literal text it contains and returns the best scoring lines
+func (l *LogReader) nearestMatches(log []byte, re *regexp.Regexp) []FuzzyMatch {
+	tokens := literalTokens(re)
+	totalLength := 0
+	for _, token := range tokens {
+		totalLength += len(token)
+	}
+	if totalLength == 0 {
+		return nil
+	}
+	var matches []FuzzyMatch
+	var offsets []int
+	offset := 0
+	for _, line := range bytes.Split(log, []byte{'\n'}) {
+		lineOffset := offset
+		offset += len(line) + 1
+		lowerLine := strings.ToLower(string(line))
+		matchedLength := 0
+		for _, token := range tokens {
+			if strings.Contains(lowerLine, token) {
+				matchedLength += len(token)
+			}
+		}
+		if matchedLength == 0 {
+			continue
+		}
+		matches = append(matches, FuzzyMatch{
+			Line:  truncateLine(string(line)),
+			Score: float64(matchedLength) / float64(totalLength),
+		})
+		offsets = append(offsets, lineOffset)
+	}
+	indexes := lo.Range(len(matches))
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return matches[indexes[i]].Score > matches[indexes[j]].Score
+	})
+	if len(indexes) > maxNearestMatches {
+		indexes = indexes[:maxNearestMatches]
+	}
+	// only the best matches are located within their files since counting lines is expensive
+	return lo.Map(indexes, func(i int, _ int) FuzzyMatch {
+		match := matches[i]
+		match.File, _, match.LineNumber = l.locateOffset(offsets[i])
+		return match
+	})
+}
+
+// literalTokens extracts the lower cased words of the literal text within a regex
+func literalTokens(re *regexp.Regexp) []string {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	var literals []string
+	var walk func(node *syntax.Regexp)
+	walk = func(node *syntax.Regexp) {
+		if node.Op == syntax.OpLiteral {
+			literals = append(literals, string(node.Rune))
+			return
+		}
+		for _, sub := range node.Sub {
+			walk(sub)
+		}
+	}
+	walk(parsed.Simplify())
+	var tokens []string
+	for _, word := range strings.Fields(strings.ToLower(strings.Join(literals, " "))) {
+		if len(word) >= minFuzzyTokenLength {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// truncateLine shortens long lines so that explanations stay readable
+func truncateLine(line string) string {
+	if len(line) <= maxExplainLineLength {
+		return line
+	}
+	truncated := line[:maxExplainLineLength]
+	for !utf8.ValidString(truncated) {
+		truncated = truncated[:len(truncated)-1]
+	}
+	return truncated + "..."
+}
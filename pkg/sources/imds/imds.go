@@ -17,11 +17,17 @@ package imds
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/samber/lo"
 
 	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
 )
@@ -32,21 +38,44 @@ var (
 	PendingTime      = fmt.Sprintf("%s/%s", DynamicDocPrefix, "pendingTime")
 )
 
+// Metadata paths with event timestamps
+const (
+	SpotInstanceActionPath      = "spot/instance-action"
+	RebalanceRecommendationPath = "events/recommendations/rebalance"
+	ScheduledMaintenancePath    = "events/maintenance/scheduled"
+)
+
+// TargetLifecycleStatePath is the Auto Scaling target lifecycle state, which is reported as metadata since IMDS does not record when it changed
+const TargetLifecycleStatePath = "autoscaling/target-lifecycle-state"
+
+const (
+	scheduledMaintenanceLayout  = "2 Jan 2006 15:04:05 GMT"
+	identityDocumentDynamicPath = "instance-identity/document"
+	metadataPathPrefix          = "meta-data/"
+)
+
 // Source is the EC2 Instance Metadata Service (IMDS) http source
 type Source struct {
-	imds *imds.Client
+	imds     *imds.Client
+	apiCalls *sources.APICalls
 }
 
 // New instantiates a new instance of the IMDS source
 func New(imdsClient *imds.Client) *Source {
 	return &Source{
-		imds: imdsClient,
+		imds:     imdsClient,
+		apiCalls: &sources.APICalls{},
 	}
 }
 
 // ClearCache is a noop for the IMDS Source since it is an http source, not a log file
 func (i Source) ClearCache() {}
 
+// APICalls returns the number of EC2 IMDS calls made by the source keyed by operation
+func (i Source) APICalls() map[string]int64 {
+	return i.apiCalls.Counts()
+}
+
 // String is a human readable string of the source
 func (i Source) String() string {
 	return Name
@@ -59,44 +88,189 @@ func (i Source) Name() string {
 
 // FindByPath is a helper func that returns a FindFunc to query IMDS for a specific HTTP path that can be used in an Event
 func (i Source) FindByPath(path string) sources.FindFunc {
-	return func(_ sources.Source, _ []byte) ([]string, error) {
-		result, err := i.GetMetadata(path)
+	return func(ctx context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		result, err := i.GetMetadata(ctx, path)
 		return []string{result}, err
 	}
 }
 
+// FindSpotInstanceAction returns a FindFunc for the time of a spot interruption notice, there are no results if the instance has no notice
+func (i Source) FindSpotInstanceAction() sources.FindFunc {
+	return i.findOptionalPath(SpotInstanceActionPath)
+}
+
+// FindRebalanceRecommendation returns a FindFunc for the notice time of a rebalance recommendation, there are no results if there is no recommendation
+func (i Source) FindRebalanceRecommendation() sources.FindFunc {
+	return i.findOptionalPath(RebalanceRecommendationPath)
+}
+
+// FindScheduledMaintenance returns a FindFunc for the start time of each scheduled maintenance event
+func (i Source) FindScheduledMaintenance() sources.FindFunc {
+	return func(ctx context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		result, err := i.GetMetadata(ctx, ScheduledMaintenancePath)
+		if sources.ErrorCategory(err) == sources.ErrorCategoryNoMatch {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var maintenanceEvents []json.RawMessage
+		if err := json.Unmarshal([]byte(result), &maintenanceEvents); err != nil {
+			return nil, sources.Errorf(sources.ErrorCategoryTimestampParse, "unable to parse scheduled maintenance events: %w", err)
+		}
+		return lo.Map(maintenanceEvents, func(e json.RawMessage, _ int) string { return string(e) }), nil
+	}
+}
+
+// TargetLifecycleState returns the Auto Scaling target lifecycle state or an empty string if the instance is not in an Auto Scaling group
+func (i Source) TargetLifecycleState(ctx context.Context) (string, error) {
+	state, err := i.GetMetadata(ctx, TargetLifecycleStatePath)
+	if sources.ErrorCategory(err) == sources.ErrorCategoryNoMatch {
+		return "", nil
+	}
+	return strings.TrimSpace(state), err
+}
+
+// findOptionalPath returns a FindFunc for a metadata path that is only available when an event occurs
+func (i Source) findOptionalPath(path string) sources.FindFunc {
+	return func(ctx context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		result, err := i.GetMetadata(ctx, path)
+		if sources.ErrorCategory(err) == sources.ErrorCategoryNoMatch {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []string{result}, nil
+	}
+}
+
 // Find will use the Event's FindFunc and CommentFunc to search the source and return the result
-func (i Source) Find(event *sources.Event) ([]sources.FindResult, error) {
-	timestamps, err := event.FindFn(i, nil)
+func (i Source) Find(ctx context.Context, event *sources.Event) ([]sources.FindResult, error) {
+	values, err := event.FindFn(ctx, i, nil)
 	if err != nil {
 		return nil, err
 	}
 	var results []sources.FindResult
-	for _, tsStr := range timestamps {
-		tsMicros, err := strconv.ParseInt(tsStr, 10, 64)
+	for _, value := range values {
 		comment := ""
 		if event.CommentFn != nil {
-			comment = event.CommentFn(tsStr)
+			comment = event.CommentFn(value)
 		}
+		timestamp, err := ParseTimeFor(value)
 		results = append(results, sources.FindResult{
-			Line:      tsStr,
-			Timestamp: time.UnixMicro(tsMicros),
+			Line:      value,
+			Timestamp: timestamp,
 			Comment:   comment,
 			Err:       err,
 		})
 	}
-	return results, nil
+	return sources.SelectMatches(results, event.MatchSelector), nil
 }
 
-// GetMetadata queries EC2 IMDS
-func (i Source) GetMetadata(path string) (string, error) {
-	ctx := context.TODO()
-	identityDoc, err := i.imds.GetInstanceIdentityDocument(ctx, &imds.GetInstanceIdentityDocumentInput{})
+// ParseTimeFor parses a timestamp in unix microseconds or RFC3339, or the time of a spot instance action, rebalance recommendation,
+// or scheduled maintenance event
+func ParseTimeFor(value string) (time.Time, error) {
+	if micros, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMicro(micros), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	var timed struct {
+		Time       string `json:"time"`
+		NoticeTime string `json:"noticeTime"`
+		NotBefore  string `json:"NotBefore"`
+	}
+	if err := json.Unmarshal([]byte(value), &timed); err == nil {
+		switch {
+		case timed.Time != "":
+			return parseTime(time.RFC3339, timed.Time)
+		case timed.NoticeTime != "":
+			return parseTime(time.RFC3339, timed.NoticeTime)
+		case timed.NotBefore != "":
+			return parseTime(scheduledMaintenanceLayout, timed.NotBefore)
+		}
+	}
+	return time.Time{}, sources.Errorf(sources.ErrorCategoryTimestampParse, "unable to parse timestamp from %q", value)
+}
+
+// parseTime parses a timestamp with a categorized error
+func parseTime(layout string, value string) (time.Time, error) {
+	t, err := time.Parse(layout, value)
 	if err != nil {
-		return "", fmt.Errorf("unable to retrieve instance-identity document: %w", err)
+		return time.Time{}, sources.NewError(sources.ErrorCategoryTimestampParse, err)
+	}
+	return t, nil
+}
+
+// CommentValue is a CommentFunc which comments the action of a spot instance action or the code of a scheduled maintenance event
+func CommentValue() sources.CommentFunc {
+	return func(matchedLine string) string {
+		var value struct {
+			Action string `json:"action"`
+			Code   string `json:"Code"`
+		}
+		if err := json.Unmarshal([]byte(matchedLine), &value); err != nil {
+			return ""
+		}
+		return lo.CoalesceOrEmpty(value.Action, value.Code)
 	}
-	if path == PendingTime {
-		return strconv.FormatInt(identityDoc.PendingTime.UnixMicro(), 10), nil
+}
+
+// Explain describes an unreachable EC2 IMDS for an event that could not be measured
+func (i Source) Explain(_ *sources.Event, err error) *sources.Explanation {
+	explanation := &sources.Explanation{}
+	if sources.ErrorCategory(err) == sources.ErrorCategoryAPI {
+		explanation.MissingPrerequisites = append(explanation.MissingPrerequisites,
+			"EC2 IMDS: the instance metadata could not be retrieved, check the IMDS endpoint and that the hop limit allows access from containers")
+	}
+	return explanation
+}
+
+// GetMetadata queries EC2 IMDS for a metadata path (i.e. instance-life-cycle) or an instance-identity document field (i.e. /dynamic/instance-identity/document/pendingTime)
+func (i Source) GetMetadata(ctx context.Context, path string) (string, error) {
+	if field, ok := strings.CutPrefix(path, DynamicDocPrefix+"/"); ok {
+		return i.getIdentityDocumentField(ctx, field)
+	}
+	i.apiCalls.Inc("GetMetadata")
+	return GetMetadata(ctx, i.imds, path)
+}
+
+// getIdentityDocumentField returns a field of the instance-identity document
+func (i Source) getIdentityDocumentField(ctx context.Context, field string) (string, error) {
+	i.apiCalls.Inc("GetDynamicData")
+	out, err := i.imds.GetDynamicData(ctx, &imds.GetDynamicDataInput{Path: identityDocumentDynamicPath})
+	if err != nil {
+		return "", sources.Errorf(sources.ErrorCategoryAPI, "unable to retrieve instance-identity document: %w", err)
+	}
+	defer out.Content.Close()
+	var doc map[string]any
+	if err := json.NewDecoder(out.Content).Decode(&doc); err != nil {
+		return "", sources.Errorf(sources.ErrorCategoryAPI, "unable to decode instance-identity document: %w", err)
+	}
+	value, ok := doc[field]
+	if !ok || value == nil {
+		return "", sources.Errorf(sources.ErrorCategoryNoMatch, "instance-identity document field \"%s\" is not available", field)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// GetMetadata queries an EC2 IMDS metadata path, a path which does not exist is a no_match error
+func GetMetadata(ctx context.Context, client *imds.Client, path string) (string, error) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "/"), metadataPathPrefix)
+	out, err := client.GetMetadata(ctx, &imds.GetMetadataInput{Path: path})
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) && statusErr.HTTPStatusCode() == http.StatusNotFound {
+		return "", sources.Errorf(sources.ErrorCategoryNoMatch, "metadata path \"%s\" is not available", path)
+	}
+	if err != nil {
+		return "", sources.Errorf(sources.ErrorCategoryAPI, "unable to retrieve metadata path \"%s\": %w", path, err)
+	}
+	defer out.Content.Close()
+	value, err := io.ReadAll(out.Content)
+	if err != nil {
+		return "", sources.Errorf(sources.ErrorCategoryAPI, "unable to read metadata path \"%s\": %w", path, err)
 	}
-	return "", fmt.Errorf("metadata for path \"%s\" is not available", path)
+	return string(value), nil
 }
@@ -0,0 +1,46 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ipamd is a latency timing source for the VPC CNI IPAM daemon (IPAMD) and CNI plugin logs in /var/log/aws-routed-eni
+package ipamd
+
+import (
+	"regexp"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+var (
+	Name        = "IPAMD"
+	DefaultPath = "/var/log/aws-routed-eni/ipamd*.log*"
+	PluginName  = "VPC CNI Plugin"
+	// DefaultPluginPath is the log of the CNI plugin binary which is called by the container runtime to set up each pod's network
+	DefaultPluginPath = "/var/log/aws-routed-eni/plugin*.log*"
+	// TimestampFormat matches the ISO8601 "ts" field of the JSON log lines, which is the first timestamp on every line
+	TimestampFormat = regexp.MustCompile(`[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?(Z|[+-][0-9]{4})`)
+	TimestampLayout = "2006-01-02T15:04:05.999999999Z0700"
+)
+
+// Source is the IPAMD or CNI plugin log source, it is an alias of sources.LogSource
+type Source = sources.LogSource
+
+// New instantiates a new instance of the IPAMD log source
+func New(path string) *Source {
+	return sources.NewLogSource(Name, path, TimestampFormat, TimestampLayout)
+}
+
+// NewPlugin instantiates a new instance of the CNI plugin log source
+func NewPlugin(path string) *Source {
+	return sources.NewLogSource(PluginName, path, TimestampFormat, TimestampLayout)
+}
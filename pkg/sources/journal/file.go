@@ -0,0 +1,307 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/samber/lo"
+)
+
+// Journal file format constants from https://systemd.io/JOURNAL_FILE_FORMAT/
+const (
+	signature              = "LPKSHHRH"
+	headerSizeOffset       = 88
+	headRealtimeOffset     = 184
+	incompatibleFlagOffset = 12
+	incompatibleCompact    = 1 << 4
+	objectHeaderSize       = 16
+	objectTypeData         = 1
+	objectTypeEntry        = 3
+	objectCompressedXZ     = 1 << 0
+	objectCompressedLZ4    = 1 << 1
+	objectCompressedZSTD   = 1 << 2
+	// entryItemsOffset is the offset of the items after the object header, seqnum, realtime, monotonic, boot_id, and xor_hash
+	entryItemsOffset = 64
+	// dataPayloadOffset is the offset of the payload after the object header, hash, and the 5 object offsets and counts
+	dataPayloadOffset = 64
+	// compactDataPayloadOffset includes the tail entry array offset and count of compact files
+	compactDataPayloadOffset = 72
+)
+
+// Entry is a single journal entry
+type Entry struct {
+	Realtime time.Time
+	BootID   string
+	// Fields are the entryFields of the entry, other fields are not read
+	Fields map[string]string
+}
+
+var (
+	// entryFields are the fields read from the data objects of an entry to format and filter it
+	entryFields = []string{"MESSAGE", "_HOSTNAME", "SYSLOG_IDENTIFIER", "_COMM", "_PID", "SYSLOG_PID", "UNIT", "_SYSTEMD_UNIT"}
+)
+
+const (
+	// readBufferSize is the size of the buffer used to walk the objects of a journal file
+	readBufferSize = 1 << 16
+	// maxFieldNameSize is the journald limit of field name bytes, it is used to read only the field name of uncompressed data objects
+	maxFieldNameSize = 64
+	// maxCachedData bounds the number of data objects cached while reading a journal file
+	maxCachedData = 4096
+)
+
+// journalFile decodes the entries of a journal file
+type journalFile struct {
+	file    *os.File
+	size    uint64
+	compact bool
+	// data caches decoded data objects by offset since entries share data objects (i.e. _HOSTNAME),
+	// messages are not cached since they are rarely shared
+	data    map[uint64]string
+	decoder *zstd.Decoder
+}
+
+// ReadEntries reads the entries of a journal file in the order they were written, an entry's data objects are only read if include returns true.
+// The tail of a file that is being written and fields that cannot be decoded are skipped.
+func ReadEntries(path string, include func(realtime time.Time, bootID string) bool) ([]*Entry, error) {
+	j, err := openJournalFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer j.Close()
+	var entries []*Entry
+	err = j.walkEntries(func(object []byte) {
+		if realtime, bootID := entryHeader(object); include(realtime, bootID) {
+			entries = append(entries, j.parseEntry(object, realtime, bootID))
+		}
+	})
+	return entries, err
+}
+
+// HeadRealtime returns the time of the first entry in a journal file from its header
+func HeadRealtime(path string) (time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+	header := make([]byte, headRealtimeOffset+8)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return time.Time{}, err
+	}
+	if string(header[:len(signature)]) != signature {
+		return time.Time{}, errors.New("not a journal file")
+	}
+	return time.UnixMicro(int64(binary.LittleEndian.Uint64(header[headRealtimeOffset:]))), nil
+}
+
+// openJournalFile opens a journal file and reads its header
+func openJournalFile(path string) (*journalFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	header := make([]byte, headerSizeOffset+8)
+	if _, err := file.ReadAt(header, 0); err != nil || string(header[:len(signature)]) != signature {
+		file.Close()
+		return nil, errors.New("not a journal file")
+	}
+	return &journalFile{
+		file:    file,
+		size:    uint64(stat.Size()),
+		compact: binary.LittleEndian.Uint32(header[incompatibleFlagOffset:])&incompatibleCompact != 0,
+		data:    map[uint64]string{},
+	}, nil
+}
+
+// Close closes the journal file and the zstd decoder
+func (j *journalFile) Close() {
+	if j.decoder != nil {
+		j.decoder.Close()
+	}
+	j.file.Close()
+}
+
+// walkEntries reads the objects of the journal file in order without reading the whole file into memory and calls fn with each entry object.
+// The object is only valid during the call.
+func (j *journalFile) walkEntries(fn func(object []byte)) error {
+	header := make([]byte, 8)
+	if _, err := j.file.ReadAt(header, headerSizeOffset); err != nil {
+		return err
+	}
+	offset := binary.LittleEndian.Uint64(header)
+	if offset > j.size {
+		return errors.New("journal header size is larger than the file")
+	}
+	reader := bufio.NewReaderSize(io.NewSectionReader(j.file, int64(offset), int64(j.size-offset)), readBufferSize)
+	var object []byte
+	for offset+objectHeaderSize <= j.size {
+		objectHeader, err := reader.Peek(objectHeaderSize)
+		if err != nil {
+			break
+		}
+		size := binary.LittleEndian.Uint64(objectHeader[8:])
+		// unused space at the end of the file or a partially written object
+		if size < objectHeaderSize || offset+size > j.size {
+			break
+		}
+		// objects are 8 byte aligned
+		next := offset + (size+7)&^7
+		if objectHeader[0] == objectTypeEntry && size >= entryItemsOffset {
+			object = slices.Grow(object[:0], int(size))[:size]
+			if _, err := io.ReadFull(reader, object); err != nil {
+				break
+			}
+			fn(object)
+			offset += size
+		}
+		if _, err := reader.Discard(int(next - offset)); err != nil {
+			break
+		}
+		offset = next
+	}
+	return nil
+}
+
+// entryHeader returns the realtime and boot ID of an entry object
+func entryHeader(object []byte) (time.Time, string) {
+	return time.UnixMicro(int64(binary.LittleEndian.Uint64(object[24:]))).UTC(), hex.EncodeToString(object[40:56])
+}
+
+// parseEntry decodes the entryFields of the data objects an entry object references
+func (j *journalFile) parseEntry(object []byte, realtime time.Time, bootID string) *Entry {
+	entry := &Entry{
+		Realtime: realtime,
+		BootID:   bootID,
+		Fields:   map[string]string{},
+	}
+	itemSize := lo.Ternary(j.compact, 4, 16)
+	for i := entryItemsOffset; i+itemSize <= len(object); i += itemSize {
+		var dataOffset uint64
+		if j.compact {
+			dataOffset = uint64(binary.LittleEndian.Uint32(object[i:]))
+		} else {
+			dataOffset = binary.LittleEndian.Uint64(object[i:])
+		}
+		field, value, err := j.readData(dataOffset)
+		if err != nil || !lo.Contains(entryFields, field) {
+			continue
+		}
+		entry.Fields[field] = value
+	}
+	return entry
+}
+
+// readData returns the field and the decompressed value of a data object.
+// Only the field name of uncompressed data objects is read unless the field is one of the entryFields.
+func (j *journalFile) readData(offset uint64) (string, string, error) {
+	if payload, ok := j.data[offset]; ok {
+		field, value, _ := strings.Cut(payload, "=")
+		return field, value, nil
+	}
+	payloadStart := uint64(lo.Ternary(j.compact, compactDataPayloadOffset, dataPayloadOffset))
+	if offset+payloadStart > j.size {
+		return "", "", fmt.Errorf("no data object at offset %d", offset)
+	}
+	object := make([]byte, min(payloadStart+maxFieldNameSize+1, j.size-offset))
+	if _, err := j.file.ReadAt(object, int64(offset)); err != nil {
+		return "", "", fmt.Errorf("unable to read data object at offset %d: %w", offset, err)
+	}
+	size := binary.LittleEndian.Uint64(object[8:])
+	if object[0] != objectTypeData {
+		return "", "", fmt.Errorf("no data object at offset %d", offset)
+	}
+	if size < payloadStart || offset+size > j.size {
+		return "", "", fmt.Errorf("truncated data object at offset %d", offset)
+	}
+	payload := object[payloadStart:min(size, uint64(len(object)))]
+	flags := object[1]
+	compressed := flags&(objectCompressedXZ|objectCompressedLZ4|objectCompressedZSTD) != 0
+	if !compressed {
+		if field, _, ok := bytes.Cut(payload, []byte("=")); !ok || !lo.Contains(entryFields, string(field)) {
+			return string(field), "", nil
+		}
+	}
+	if uint64(len(object)) < size {
+		payload = make([]byte, size-payloadStart)
+		if _, err := j.file.ReadAt(payload, int64(offset+payloadStart)); err != nil {
+			return "", "", fmt.Errorf("unable to read data object at offset %d: %w", offset, err)
+		}
+	}
+	var err error
+	switch {
+	case flags&objectCompressedZSTD != 0:
+		if j.decoder == nil {
+			if j.decoder, err = zstd.NewReader(nil); err != nil {
+				return "", "", err
+			}
+		}
+		payload, err = j.decoder.DecodeAll(payload, nil)
+	case flags&objectCompressedLZ4 != 0:
+		payload, err = decodeLZ4(payload)
+	case flags&objectCompressedXZ != 0:
+		err = errors.New("xz compressed data objects are not supported")
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("unable to decompress data object at offset %d: %w", offset, err)
+	}
+	field, value, _ := strings.Cut(string(payload), "=")
+	if field != "MESSAGE" && lo.Contains(entryFields, field) {
+		if len(j.data) >= maxCachedData {
+			clear(j.data)
+		}
+		j.data[offset] = string(payload)
+	}
+	return field, value, nil
+}
+
+// decodeLZ4 decodes journald's LZ4 payloads which are the 64-bit uncompressed size followed by an LZ4 block
+func decodeLZ4(src []byte) ([]byte, error) {
+	if len(src) < 8 {
+		return nil, errors.New("lz4 payload is too short")
+	}
+	size := binary.LittleEndian.Uint64(src)
+	// each byte of an LZ4 block decodes to at most 255 bytes, which bounds the allocation for a corrupt size
+	if size > uint64(len(src)-8)*255 {
+		return nil, fmt.Errorf("lz4 payload size %d is larger than its block can decode to", size)
+	}
+	dst := make([]byte, size)
+	n, err := lz4.UncompressBlock(src[8:], dst)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(n) != size {
+		return nil, fmt.Errorf("lz4 payload decoded to %d bytes instead of %d", n, size)
+	}
+	return dst, nil
+}
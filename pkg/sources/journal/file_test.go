@@ -0,0 +1,298 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package journal
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/samber/lo"
+)
+
+const (
+	testHeaderSize = 256
+	testBootA      = "0123456789abcdef0123456789abcdef"
+	testBootB      = "fedcba9876543210fedcba9876543210"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testJournal builds a journal file in the regular or compact format with only the header fields and objects the reader uses
+type testJournal struct {
+	compact bool
+	buf     []byte
+}
+
+func newTestJournal(compact bool) *testJournal {
+	j := &testJournal{compact: compact, buf: make([]byte, testHeaderSize)}
+	copy(j.buf, signature)
+	if compact {
+		binary.LittleEndian.PutUint32(j.buf[incompatibleFlagOffset:], incompatibleCompact)
+	}
+	binary.LittleEndian.PutUint64(j.buf[headerSizeOffset:], testHeaderSize)
+	binary.LittleEndian.PutUint64(j.buf[headRealtimeOffset:], uint64(testStart.UnixMicro()))
+	return j
+}
+
+// addObject appends an object with the header and returns its offset, objects are 8 byte aligned
+func (j *testJournal) addObject(objectType byte, flags byte, body []byte) uint64 {
+	offset := uint64(len(j.buf))
+	header := make([]byte, objectHeaderSize)
+	header[0], header[1] = objectType, flags
+	binary.LittleEndian.PutUint64(header[8:], uint64(objectHeaderSize+len(body)))
+	j.buf = append(j.buf, header...)
+	j.buf = append(j.buf, body...)
+	j.buf = append(j.buf, make([]byte, (8-len(j.buf)%8)%8)...)
+	return offset
+}
+
+// addData appends a data object with the payload, which is compressed according to the flags
+func (j *testJournal) addData(t *testing.T, flags byte, payload string) uint64 {
+	t.Helper()
+	stored := []byte(payload)
+	switch flags {
+	case objectCompressedLZ4:
+		block := make([]byte, lz4.CompressBlockBound(len(payload)))
+		n, err := lz4.CompressBlock([]byte(payload), block, nil)
+		if err != nil || n == 0 {
+			t.Fatalf("unable to lz4 compress %q: %v", payload, err)
+		}
+		stored = binary.LittleEndian.AppendUint64(nil, uint64(len(payload)))
+		stored = append(stored, block[:n]...)
+	case objectCompressedZSTD:
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored = encoder.EncodeAll([]byte(payload), nil)
+		encoder.Close()
+	}
+	// the hash, object offsets, and counts are not used by the reader
+	body := make([]byte, lo.Ternary(j.compact, compactDataPayloadOffset, dataPayloadOffset)-objectHeaderSize)
+	return j.addObject(objectTypeData, flags, append(body, stored...))
+}
+
+// addEntry appends an entry object referencing the data objects
+func (j *testJournal) addEntry(realtime time.Time, bootID string, dataOffsets ...uint64) uint64 {
+	body := make([]byte, entryItemsOffset-objectHeaderSize)
+	binary.LittleEndian.PutUint64(body[24-objectHeaderSize:], uint64(realtime.UnixMicro()))
+	id, _ := hex.DecodeString(bootID)
+	copy(body[40-objectHeaderSize:], id)
+	for _, offset := range dataOffsets {
+		if j.compact {
+			body = binary.LittleEndian.AppendUint32(body, uint32(offset))
+		} else {
+			body = binary.LittleEndian.AppendUint64(body, offset)
+			body = binary.LittleEndian.AppendUint64(body, 0)
+		}
+	}
+	return j.addObject(objectTypeEntry, 0, body)
+}
+
+// write writes the journal to a file in a temporary directory and returns its path
+func (j *testJournal) write(t *testing.T, contents []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "system.journal")
+	if err := os.WriteFile(path, contents, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// buildTestJournal returns a journal with two entries of boot A and one of boot B whose messages are compressed with the flags
+func buildTestJournal(t *testing.T, compact bool, flags byte) *testJournal {
+	t.Helper()
+	j := newTestJournal(compact)
+	hostname := j.addData(t, 0, "_HOSTNAME=ip-192-168-0-1")
+	transport := j.addData(t, 0, "_TRANSPORT=journal")
+	kubelet := j.addData(t, 0, "SYSLOG_IDENTIFIER=kubelet")
+	unit := j.addData(t, 0, "_SYSTEMD_UNIT=kubelet.service")
+	pid := j.addData(t, 0, "_PID=42")
+	// repeated runs give the LZ4 block overlapping matches and long length runs
+	long := "MESSAGE=" + strings.Repeat("ab", 200) + " Started kubelet"
+	j.addEntry(testStart, testBootA, hostname, transport, kubelet, unit, pid, j.addData(t, flags, "MESSAGE=Starting kubelet"))
+	j.addEntry(testStart.Add(time.Second), testBootA, hostname, kubelet, unit, pid, j.addData(t, flags, long))
+	j.addEntry(testStart.Add(time.Hour), testBootB, hostname, transport, j.addData(t, flags, "MESSAGE=Linux version 6.1"))
+	return j
+}
+
+func TestReadEntries(t *testing.T) {
+	for _, format := range []struct {
+		name    string
+		compact bool
+	}{{name: "regular", compact: false}, {name: "compact", compact: true}} {
+		for _, compression := range []struct {
+			name  string
+			flags byte
+		}{{name: "uncompressed"}, {name: "lz4", flags: objectCompressedLZ4}, {name: "zstd", flags: objectCompressedZSTD}} {
+			t.Run(format.name+"/"+compression.name, func(t *testing.T) {
+				j := buildTestJournal(t, format.compact, compression.flags)
+				path := j.write(t, j.buf)
+				entries, err := ReadEntries(path, func(time.Time, string) bool { return true })
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(entries) != 3 {
+					t.Fatalf("expected 3 entries, got %d", len(entries))
+				}
+				expected := []struct {
+					realtime time.Time
+					bootID   string
+					message  string
+				}{
+					{realtime: testStart, bootID: testBootA, message: "Starting kubelet"},
+					{realtime: testStart.Add(time.Second), bootID: testBootA, message: strings.Repeat("ab", 200) + " Started kubelet"},
+					{realtime: testStart.Add(time.Hour), bootID: testBootB, message: "Linux version 6.1"},
+				}
+				for i, entry := range entries {
+					if !entry.Realtime.Equal(expected[i].realtime) || entry.BootID != expected[i].bootID || entry.Fields["MESSAGE"] != expected[i].message {
+						t.Errorf("entry %d: expected %v %s %q, got %v %s %q", i, expected[i].realtime, expected[i].bootID, expected[i].message,
+							entry.Realtime, entry.BootID, entry.Fields["MESSAGE"])
+					}
+					if entry.Fields["_HOSTNAME"] != "ip-192-168-0-1" {
+						t.Errorf("entry %d: expected the shared hostname data object, got %q", i, entry.Fields["_HOSTNAME"])
+					}
+					if _, ok := entry.Fields["_TRANSPORT"]; ok {
+						t.Errorf("entry %d: expected fields other than the entry fields to be skipped", i)
+					}
+				}
+				if got := FormatEntry(entries[0]); got != "2024-01-01T00:00:00.000000Z ip-192-168-0-1 kubelet[42]: Starting kubelet" {
+					t.Errorf("unexpected formatted entry %q", got)
+				}
+			})
+		}
+	}
+}
+
+func TestReadEntriesOnlyParsesIncludedEntries(t *testing.T) {
+	j := buildTestJournal(t, false, 0)
+	path := j.write(t, j.buf)
+	entries, err := ReadEntries(path, func(_ time.Time, bootID string) bool { return bootID == testBootB })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Fields["MESSAGE"] != "Linux version 6.1" {
+		t.Fatalf("expected only the entry of boot B, got %+v", entries)
+	}
+	head, err := HeadRealtime(path)
+	if err != nil || !head.Equal(testStart) {
+		t.Errorf("expected the head realtime %v, got %v %v", testStart, head, err)
+	}
+}
+
+func TestReadEntriesCorrupt(t *testing.T) {
+	for _, compact := range []bool{false, true} {
+		for _, flags := range []byte{0, objectCompressedLZ4, objectCompressedZSTD} {
+			j := buildTestJournal(t, compact, flags)
+			dir := t.TempDir()
+			read := func(contents []byte) {
+				path := filepath.Join(dir, "system.journal")
+				if err := os.WriteFile(path, contents, 0o600); err != nil {
+					t.Fatal(err)
+				}
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					_, _ = ReadEntries(path, func(time.Time, string) bool { return true })
+				}()
+				select {
+				case <-done:
+				case <-time.After(10 * time.Second):
+					t.Fatalf("reading a corrupt journal (compact=%t, flags=%d) did not return", compact, flags)
+				}
+			}
+			// a file that is being written may be truncated anywhere
+			for size := 0; size < len(j.buf); size++ {
+				read(j.buf[:size])
+			}
+			// every byte of the objects is corrupted in turn, including object sizes, types, data offsets, and compressed payloads
+			for i := testHeaderSize; i < len(j.buf); i++ {
+				corrupt := append([]byte{}, j.buf...)
+				corrupt[i] ^= 0xff
+				read(corrupt)
+			}
+		}
+	}
+}
+
+func TestReadEntriesRejectsInvalidFiles(t *testing.T) {
+	j := buildTestJournal(t, false, 0)
+	for _, tc := range []struct {
+		name     string
+		contents func() []byte
+	}{
+		{name: "empty", contents: func() []byte { return nil }},
+		{name: "not a journal", contents: func() []byte { return []byte(strings.Repeat("not a journal file", 20)) }},
+		{name: "header size beyond the file", contents: func() []byte {
+			contents := append([]byte{}, j.buf...)
+			binary.LittleEndian.PutUint64(contents[headerSizeOffset:], uint64(len(contents)+8))
+			return contents
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := j.write(t, tc.contents())
+			if _, err := ReadEntries(path, func(time.Time, string) bool { return true }); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestDecodeLZ4(t *testing.T) {
+	payload := []byte("MESSAGE=" + strings.Repeat("a", 300) + strings.Repeat("xyz", 100))
+	block := make([]byte, lz4.CompressBlockBound(len(payload)))
+	n, err := lz4.CompressBlock(payload, block, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := append(binary.LittleEndian.AppendUint64(nil, uint64(len(payload))), block[:n]...)
+	withSize := func(size uint64) []byte {
+		src := append([]byte{}, valid...)
+		binary.LittleEndian.PutUint64(src, size)
+		return src
+	}
+	for _, tc := range []struct {
+		name    string
+		src     []byte
+		wantErr bool
+	}{
+		{name: "valid", src: valid},
+		{name: "shorter than the size", src: []byte{1, 2, 3}, wantErr: true},
+		{name: "truncated block", src: valid[:len(valid)-4], wantErr: true},
+		{name: "size larger than the decoded block", src: withSize(uint64(len(payload) + 1)), wantErr: true},
+		{name: "size larger than the block can decode to", src: withSize(1 << 62), wantErr: true},
+		{name: "invalid match offset", src: append(binary.LittleEndian.AppendUint64(nil, 8), 0x04, 'a', 0xff, 0xff), wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			decoded, err := decodeLZ4(tc.src)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %d bytes", len(decoded))
+				}
+				return
+			}
+			if err != nil || string(decoded) != string(payload) {
+				t.Errorf("expected the payload, got %q %v", decoded, err)
+			}
+		})
+	}
+}
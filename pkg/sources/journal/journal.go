@@ -0,0 +1,119 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package journal is a latency timing source for the systemd journal
+package journal
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/samber/lo"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+var (
+	Name = "Journal"
+	// DefaultPaths are the persistent and volatile system journal files, the first path with journal files is used
+	DefaultPaths    = []string{"/var/log/journal/*/system*.journal*", "/run/log/journal/*/system*.journal*"}
+	TimestampFormat = regexp.MustCompile(`[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}\.[0-9]{6}Z`)
+	TimestampLayout = "2006-01-02T15:04:05.999999Z"
+)
+
+// Source is the systemd journal source, it is a LogSource of the journal entries formatted like journalctl's output
+type Source struct {
+	*sources.LogSource
+	units  []string
+	reader *Reader
+}
+
+// New instantiates a new instance of the journal source
+func New(path string) *Source {
+	return NewForUnits(Name, path)
+}
+
+// NewForUnits instantiates a journal source restricted to the entries of the systemd units and the entries systemd logs about them
+func NewForUnits(name string, path string, units ...string) *Source {
+	s := &Source{
+		LogSource: sources.NewLogSource(name, path, TimestampFormat, TimestampLayout),
+		units:     units,
+		reader:    NewReader(),
+	}
+	logReader := s.LogReader()
+	logReader.Before = before
+	// only the entries of the boot the source is scoped to are parsed
+	logReader.ReadFile = func(path string) ([]byte, error) {
+		return s.reader.ReadFile(path, logReader.Boot(), s.units...)
+	}
+	return s
+}
+
+// WithReader shares a Reader with other journal sources of the same path so that the journal files are only parsed once
+func (s *Source) WithReader(reader *Reader) *Source {
+	s.reader = reader
+	return s
+}
+
+// before orders journal files by their first entry since archived files may be modified after the active file is created
+func before(a string, b string) bool {
+	aTime, aErr := HeadRealtime(a)
+	bTime, bErr := HeadRealtime(b)
+	if aErr != nil || bErr != nil {
+		return a < b
+	}
+	return aTime.Before(bTime)
+}
+
+// DefaultPath returns the first of the DefaultPaths with journal files or the persistent journal if there are none
+func DefaultPath() string {
+	path, ok := lo.Find(DefaultPaths, func(path string) bool {
+		matches, err := filepath.Glob(path)
+		return err == nil && len(matches) != 0
+	})
+	return lo.Ternary(ok, path, DefaultPaths[0])
+}
+
+// FormatEntry formats an entry like journalctl's short-iso-precise output in UTC with multi-line messages joined by spaces
+func FormatEntry(entry *Entry) string {
+	identifier := lo.CoalesceOrEmpty(entry.Fields["SYSLOG_IDENTIFIER"], entry.Fields["_COMM"], "unknown")
+	if pid := lo.CoalesceOrEmpty(entry.Fields["_PID"], entry.Fields["SYSLOG_PID"]); pid != "" {
+		identifier = fmt.Sprintf("%s[%s]", identifier, pid)
+	}
+	message := strings.TrimRight(strings.ReplaceAll(entry.Fields["MESSAGE"], "\n", " "), " ")
+	return fmt.Sprintf("%s %s %s: %s", entry.Realtime.Format("2006-01-02T15:04:05.000000Z"), entry.Fields["_HOSTNAME"], identifier, message)
+}
+
+// WithCurrentBootOnly restricts the journal files to those modified since the current boot
+func (s *Source) WithCurrentBootOnly(currentBootOnly bool) *Source {
+	s.LogSource.WithCurrentBootOnly(currentBootOnly)
+	return s
+}
+
+// ClearCache will clear the log reader cache and the entries cached by the Reader
+func (s *Source) ClearCache() {
+	s.LogSource.ClearCache()
+	s.reader.ClearCache()
+}
+
+// Boots returns the boots found in the journal from the boot IDs of the entries
+func (s *Source) Boots() ([]*sources.Boot, error) {
+	paths, err := s.LogReader().Files()
+	if err != nil {
+		return nil, err
+	}
+	return s.reader.Boots(paths)
+}
@@ -0,0 +1,164 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package journal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+// Reader parses journal files once for all of the sources sharing it (i.e. the journal and the sources restricted to units).
+// It is safe for concurrent use since sources are measured concurrently.
+type Reader struct {
+	mu sync.Mutex
+	// boot is the boot the cached entries were read for, entries of other boots are not parsed
+	boot    *sources.Boot
+	entries map[string][]*Entry
+	// fileBoots caches the boots of each file until it is modified
+	fileBoots map[string]*fileBoots
+}
+
+// fileBoots are the boots of a journal file when it had the size and modification time
+type fileBoots struct {
+	size    int64
+	modTime time.Time
+	boots   []sources.Boot
+}
+
+// NewReader instantiates a new journal Reader
+func NewReader() *Reader {
+	return &Reader{entries: map[string][]*Entry{}, fileBoots: map[string]*fileBoots{}}
+}
+
+// ClearCache clears the cached entries so that entries written since they were read are included
+func (r *Reader) ClearCache() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clear(r.entries)
+}
+
+// ReadFile reads a journal file into journalctl style lines with a "-- Boot <id> --" line at the start of each boot.
+// If a boot is passed, only entries of the boot are included and if units are passed, only entries of the units are included.
+func (r *Reader) ReadFile(path string, boot *sources.Boot, units ...string) ([]byte, error) {
+	entries, err := r.readEntries(path, boot)
+	if err != nil {
+		return nil, sources.Errorf(sources.ErrorCategorySourceUnavailable, "unable to read journal file %s: %w", path, err)
+	}
+	var b strings.Builder
+	bootID := ""
+	for _, entry := range entries {
+		if len(units) != 0 && !lo.Contains(units, entry.Fields["UNIT"]) && !lo.Contains(units, entry.Fields["_SYSTEMD_UNIT"]) {
+			continue
+		}
+		if entry.BootID != bootID {
+			bootID = entry.BootID
+			fmt.Fprintf(&b, "-- Boot %s --\n", bootID)
+		}
+		b.WriteString(FormatEntry(entry))
+		b.WriteByte('\n')
+	}
+	return []byte(b.String()), nil
+}
+
+// readEntries returns the cached entries of the file for the boot or reads them
+func (r *Reader) readEntries(path string, boot *sources.Boot) ([]*Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.boot.Equal(boot) {
+		clear(r.entries)
+		r.boot = boot
+	}
+	if entries, ok := r.entries[path]; ok {
+		return entries, nil
+	}
+	entries, err := ReadEntries(path, func(realtime time.Time, bootID string) bool {
+		switch {
+		case boot == nil:
+			return true
+		case boot.ID != "":
+			return bootID == boot.ID
+		}
+		return boot.Contains(realtime)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.entries[path] = entries
+	return entries, nil
+}
+
+// Boots returns the boots of the journal files, ordered from oldest to newest, from the boot IDs of their entries
+func (r *Reader) Boots(paths []string) ([]*sources.Boot, error) {
+	var boots []*sources.Boot
+	var errs error
+	read := 0
+	for _, path := range paths {
+		pathBoots, err := r.readBoots(path)
+		if err != nil {
+			errs = multierr.Append(errs, sources.Errorf(sources.ErrorCategorySourceUnavailable, "unable to read journal file %s: %w", path, err))
+			continue
+		}
+		read++
+		for _, boot := range pathBoots {
+			// each journal file of a boot starts with the same boot ID
+			if len(boots) != 0 && boots[len(boots)-1].ID == boot.ID {
+				continue
+			}
+			boots = append(boots, &sources.Boot{Index: len(boots) + 1, ID: boot.ID, Start: boot.Start})
+		}
+	}
+	// files that cannot be read are skipped as long as at least one file could be read
+	if read == 0 {
+		return nil, errs
+	}
+	return sources.CompleteBoots(boots), nil
+}
+
+// readBoots returns the ID and the time of the first entry of each boot in a journal file, only entry headers are read
+func (r *Reader) readBoots(path string) ([]sources.Boot, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cached, ok := r.fileBoots[path]; ok && cached.size == stat.Size() && cached.modTime.Equal(stat.ModTime()) {
+		return cached.boots, nil
+	}
+	j, err := openJournalFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer j.Close()
+	var boots []sources.Boot
+	if err := j.walkEntries(func(object []byte) {
+		realtime, bootID := entryHeader(object)
+		if len(boots) == 0 || boots[len(boots)-1].ID != bootID {
+			boots = append(boots, sources.Boot{ID: bootID, Start: realtime})
+		}
+	}); err != nil {
+		return nil, err
+	}
+	r.fileBoots[path] = &fileBoots{size: stat.Size(), modTime: stat.ModTime(), boots: boots}
+	return boots, nil
+}
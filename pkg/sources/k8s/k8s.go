@@ -39,6 +39,7 @@ type Source struct {
 	clientset    *kubernetes.Clientset
 	nodeName     string
 	podNamespace string
+	apiCalls     *sources.APICalls
 }
 
 // New instantiates a new instance of the K8s API source
@@ -47,12 +48,18 @@ func New(clientset *kubernetes.Clientset, nodeName string, podNamespace string)
 		clientset:    clientset,
 		nodeName:     nodeName,
 		podNamespace: podNamespace,
+		apiCalls:     &sources.APICalls{},
 	}
 }
 
 // ClearCache is a noop for the K8s API Source since it is an http source, not a log file
 func (s Source) ClearCache() {}
 
+// APICalls returns the number of K8s API calls made by the source keyed by operation
+func (s Source) APICalls() map[string]int64 {
+	return s.apiCalls.Counts()
+}
+
 // String is a human readable string of the source
 func (s Source) String() string {
 	return Name
@@ -65,11 +72,11 @@ func (s Source) Name() string {
 
 // FindPodCreationTime retrieves the Pod creation time
 func (s *Source) FindPodCreationTime() sources.FindFunc {
-	return func(_ sources.Source, _ []byte) ([]string, error) {
-		ctx := context.Background()
+	return func(ctx context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		s.apiCalls.Inc("ListPods")
 		pods, err := s.clientset.CoreV1().Pods(s.podNamespace).List(ctx, v1.ListOptions{FieldSelector: fmt.Sprintf("spec.nodeName=%s", s.nodeName)})
 		if err != nil {
-			return nil, err
+			return nil, sources.NewError(sources.ErrorCategoryAPI, err)
 		}
 		podMatches := lo.Map(pods.Items, func(p corev1.Pod, _ int) string {
 			podBytes, err := json.Marshal(p)
@@ -82,18 +89,31 @@ func (s *Source) FindPodCreationTime() sources.FindFunc {
 	}
 }
 
+// Explain describes a missing node name or a failed K8s API call for an event that could not be measured
+func (s *Source) Explain(_ *sources.Event, err error) *sources.Explanation {
+	explanation := &sources.Explanation{}
+	if s.nodeName == "" {
+		explanation.MissingPrerequisites = append(explanation.MissingPrerequisites, "node name: the node name is required to find pods scheduled to the node")
+	}
+	if sources.ErrorCategory(err) == sources.ErrorCategoryAPI {
+		explanation.MissingPrerequisites = append(explanation.MissingPrerequisites,
+			fmt.Sprintf("K8s API: unable to list pods in namespace %q, check the K8s credentials and RBAC permissions", s.podNamespace))
+	}
+	return explanation
+}
+
 // ParseTimeFor parses an event and returns the time
 func (s *Source) ParseTimeFor(event []byte) (time.Time, error) {
 	var pod *corev1.Pod
 	if err := json.Unmarshal(event, &pod); err == nil && !pod.CreationTimestamp.IsZero() {
 		return pod.CreationTimestamp.Time, nil
 	}
-	return time.Time{}, fmt.Errorf("unable to parse event")
+	return time.Time{}, sources.Errorf(sources.ErrorCategoryTimestampParse, "unable to parse event")
 }
 
 // Find will use the Event's FindFunc and CommentFunc to search the source and return the result
-func (s *Source) Find(event *sources.Event) ([]sources.FindResult, error) {
-	k8sEvents, err := event.FindFn(s, nil)
+func (s *Source) Find(ctx context.Context, event *sources.Event) ([]sources.FindResult, error) {
+	k8sEvents, err := event.FindFn(ctx, s, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,271 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package karpenter is a latency timing source for Karpenter NodeClaim lifecycle events
+package karpenter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+var (
+	Name = "Karpenter"
+	// NodeClaimResources are the NodeClaim API versions in order of preference
+	NodeClaimResources = []schema.GroupVersionResource{
+		{Group: "karpenter.sh", Version: "v1", Resource: "nodeclaims"},
+		{Group: "karpenter.sh", Version: "v1beta1", Resource: "nodeclaims"},
+	}
+	nodeResource = schema.GroupVersionResource{Version: "v1", Resource: "nodes"}
+)
+
+// NodeClaim condition types
+const (
+	// ConditionCreated is not a NodeClaim condition, it is the NodeClaim's creation time which is when capacity was requested
+	ConditionCreated     = "Created"
+	ConditionLaunched    = "Launched"
+	ConditionRegistered  = "Registered"
+	ConditionInitialized = "Initialized"
+	ConditionReady       = "Ready"
+)
+
+// NodeClaim labels
+const (
+	NodePoolLabel     = "karpenter.sh/nodepool"
+	CapacityTypeLabel = "karpenter.sh/capacity-type"
+)
+
+// Condition is a NodeClaim status condition
+type Condition struct {
+	Type               string    `json:"type"`
+	Status             string    `json:"status"`
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+	Reason             string    `json:"reason,omitempty"`
+	Message            string    `json:"message,omitempty"`
+}
+
+// Source is the Karpenter NodeClaim K8s API source
+type Source struct {
+	dynamicClient dynamic.Interface
+	resource      schema.GroupVersionResource
+	nodeName      string
+	apiCalls      *sources.APICalls
+	mu            *sync.Mutex
+	nodeClaim     *unstructured.Unstructured
+	// nodeClaimName is resolved from the node's owner reference once and is kept across cache clears
+	nodeClaimName string
+}
+
+// New instantiates a new instance of the Karpenter NodeClaim source for the NodeClaim API version resource
+func New(dynamicClient dynamic.Interface, resource schema.GroupVersionResource, nodeName string) *Source {
+	return &Source{
+		dynamicClient: dynamicClient,
+		resource:      resource,
+		nodeName:      nodeName,
+		apiCalls:      &sources.APICalls{},
+		mu:            &sync.Mutex{},
+	}
+}
+
+// DiscoverNodeClaimResource returns the preferred NodeClaim API version served by the cluster or an error if Karpenter is not installed
+func DiscoverNodeClaimResource(discoveryClient discovery.DiscoveryInterface) (schema.GroupVersionResource, error) {
+	for _, resource := range NodeClaimResources {
+		resources, err := discoveryClient.ServerResourcesForGroupVersion(resource.GroupVersion().String())
+		if err != nil {
+			continue
+		}
+		if lo.ContainsBy(resources.APIResources, func(r v1.APIResource) bool { return r.Name == resource.Resource }) {
+			return resource, nil
+		}
+	}
+	return schema.GroupVersionResource{}, fmt.Errorf("no NodeClaim API found in versions %v", lo.Map(NodeClaimResources, func(r schema.GroupVersionResource, _ int) string {
+		return r.GroupVersion().String()
+	}))
+}
+
+// ClearCache clears the cached NodeClaim, the NodeClaim name is kept since a node's NodeClaim does not change
+func (s *Source) ClearCache() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodeClaim = nil
+}
+
+// String is a human readable string of the source
+func (s *Source) String() string {
+	return fmt.Sprintf("%s %s", Name, s.resource.GroupVersion())
+}
+
+// Name is the name of the source
+func (s *Source) Name() string {
+	return Name
+}
+
+// APICalls returns the number of K8s API calls made by the source keyed by operation
+func (s *Source) APICalls() map[string]int64 {
+	return s.apiCalls.Counts()
+}
+
+// FindCondition retrieves the last transition of a NodeClaim condition to True or the NodeClaim's creation time for ConditionCreated
+func (s *Source) FindCondition(conditionType string) sources.FindFunc {
+	return func(ctx context.Context, _ sources.Source, _ []byte) ([]string, error) {
+		nodeClaim, err := s.GetNodeClaim(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var condition Condition
+		if conditionType == ConditionCreated {
+			condition = Condition{Type: ConditionCreated, Status: string(v1.ConditionTrue), LastTransitionTime: nodeClaim.GetCreationTimestamp().UTC()}
+		} else {
+			conditions, err := nodeClaimConditions(nodeClaim)
+			if err != nil {
+				return nil, err
+			}
+			found, ok := lo.Find(conditions, func(c Condition) bool { return c.Type == conditionType && c.Status == string(v1.ConditionTrue) })
+			if !ok {
+				return nil, sources.Errorf(sources.ErrorCategoryNoMatch, "NodeClaim %s condition %s is not True", nodeClaim.GetName(), conditionType)
+			}
+			condition = found
+		}
+		conditionBytes, err := json.Marshal(condition)
+		return []string{string(conditionBytes)}, err
+	}
+}
+
+// GetNodeClaim returns the NodeClaim of the node, the NodeClaim is cached until the cache is cleared
+func (s *Source) GetNodeClaim(ctx context.Context) (*unstructured.Unstructured, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nodeClaim != nil {
+		return s.nodeClaim, nil
+	}
+	nodeClaimName, err := s.getNodeClaimName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.apiCalls.Inc("GetNodeClaim")
+	nodeClaim, err := s.dynamicClient.Resource(s.resource).Get(ctx, nodeClaimName, v1.GetOptions{})
+	if err != nil {
+		return nil, sources.NewError(sources.ErrorCategoryAPI, err)
+	}
+	s.nodeClaim = nodeClaim
+	return s.nodeClaim, nil
+}
+
+// getNodeClaimName returns the name of the NodeClaim which owns the node so that the NodeClaim can be retrieved without listing every NodeClaim
+func (s *Source) getNodeClaimName(ctx context.Context) (string, error) {
+	if s.nodeClaimName != "" {
+		return s.nodeClaimName, nil
+	}
+	if s.nodeName == "" {
+		return "", sources.Errorf(sources.ErrorCategoryMissingPrerequisite, "unable to find the NodeClaim because the node name is not known")
+	}
+	s.apiCalls.Inc("GetNode")
+	node, err := s.dynamicClient.Resource(nodeResource).Get(ctx, s.nodeName, v1.GetOptions{})
+	if err != nil {
+		return "", sources.NewError(sources.ErrorCategoryAPI, err)
+	}
+	ownerReference, ok := lo.Find(node.GetOwnerReferences(), func(ref v1.OwnerReference) bool {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		return err == nil && gv.Group == s.resource.Group && ref.Kind == "NodeClaim"
+	})
+	if !ok {
+		return "", sources.Errorf(sources.ErrorCategoryNoMatch, "node %s is not owned by a NodeClaim", s.nodeName)
+	}
+	s.nodeClaimName = ownerReference.Name
+	return s.nodeClaimName, nil
+}
+
+// NodeClaimLabels returns the NodePool name and capacity type of the node's NodeClaim
+func (s *Source) NodeClaimLabels(ctx context.Context) (nodePool string, capacityType string, err error) {
+	nodeClaim, err := s.GetNodeClaim(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	labels := nodeClaim.GetLabels()
+	return labels[NodePoolLabel], labels[CapacityTypeLabel], nil
+}
+
+// ParseTimeFor parses a condition and returns its last transition time
+func (s *Source) ParseTimeFor(event []byte) (time.Time, error) {
+	var condition Condition
+	if err := json.Unmarshal(event, &condition); err != nil || condition.LastTransitionTime.IsZero() {
+		return time.Time{}, sources.Errorf(sources.ErrorCategoryTimestampParse, "unable to parse NodeClaim condition")
+	}
+	return condition.LastTransitionTime, nil
+}
+
+// Find will use the Event's FindFunc and CommentFunc to search the source and return the result
+func (s *Source) Find(ctx context.Context, event *sources.Event) ([]sources.FindResult, error) {
+	conditions, err := event.FindFn(ctx, s, nil)
+	if err != nil {
+		return nil, err
+	}
+	var results []sources.FindResult
+	for _, condition := range conditions {
+		comment := ""
+		if event.CommentFn != nil {
+			comment = event.CommentFn(condition)
+		}
+		eventTime, err := s.ParseTimeFor([]byte(condition))
+		results = append(results, sources.FindResult{
+			Line:      condition,
+			Timestamp: eventTime,
+			Comment:   comment,
+			Err:       err,
+		})
+	}
+	return sources.SelectMatches(results, event.MatchSelector), nil
+}
+
+// Explain describes a missing node name, NodeClaim, or a failed K8s API call for an event that could not be measured
+func (s *Source) Explain(_ *sources.Event, err error) *sources.Explanation {
+	explanation := &sources.Explanation{}
+	switch sources.ErrorCategory(err) {
+	case sources.ErrorCategoryMissingPrerequisite:
+		explanation.MissingPrerequisites = append(explanation.MissingPrerequisites, "node name: the node name is required to find the node's NodeClaim")
+	case sources.ErrorCategoryAPI:
+		explanation.MissingPrerequisites = append(explanation.MissingPrerequisites,
+			fmt.Sprintf("K8s API: unable to get the node or %s, check the K8s credentials and RBAC permissions", s.resource))
+	}
+	return explanation
+}
+
+// nodeClaimConditions decodes the status conditions of a NodeClaim
+func nodeClaimConditions(nodeClaim *unstructured.Unstructured) ([]Condition, error) {
+	rawConditions, _, err := unstructured.NestedSlice(nodeClaim.Object, "status", "conditions")
+	if err != nil {
+		return nil, sources.Errorf(sources.ErrorCategoryTimestampParse, "unable to read NodeClaim %s conditions: %w", nodeClaim.GetName(), err)
+	}
+	conditionsBytes, err := json.Marshal(rawConditions)
+	if err != nil {
+		return nil, sources.Errorf(sources.ErrorCategoryTimestampParse, "unable to read NodeClaim %s conditions: %w", nodeClaim.GetName(), err)
+	}
+	var conditions []Condition
+	if err := json.Unmarshal(conditionsBytes, &conditions); err != nil {
+		return nil, sources.Errorf(sources.ErrorCategoryTimestampParse, "unable to read NodeClaim %s conditions: %w", nodeClaim.GetName(), err)
+	}
+	return conditions, nil
+}
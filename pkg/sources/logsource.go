@@ -0,0 +1,160 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// matchOffsetsKey is the context key of the match offsets that FindByRegex reports to Find
+type matchOffsetsKey struct{}
+
+// LogSource is a latency timing source for log files whose lines are prefixed by a timestamp (i.e. syslog, cloud-init, and pod logs)
+type LogSource struct {
+	name      string
+	logReader *LogReader
+}
+
+// NewLogSource instantiates a log source named name for the files matching the path glob with timestamps matching the regex and layout
+func NewLogSource(name string, path string, timestampRegex *regexp.Regexp, timestampLayout string) *LogSource {
+	return &LogSource{
+		name: name,
+		logReader: &LogReader{
+			Path:            path,
+			Glob:            true,
+			TimestampRegex:  timestampRegex,
+			TimestampLayout: timestampLayout,
+		},
+	}
+}
+
+// LogReader returns the LogReader of the source so that sources built on the LogSource can customize how files are read
+func (s *LogSource) LogReader() *LogReader {
+	return s.logReader
+}
+
+// WithAltTimestampLayouts sets the layouts that are tried in order when a timestamp does not match the timestamp layout
+func (s *LogSource) WithAltTimestampLayouts(layouts ...string) *LogSource {
+	s.logReader.AltTimestampLayouts = layouts
+	return s
+}
+
+// WithLocation sets the time zone of timestamps without a zone
+func (s *LogSource) WithLocation(loc *time.Location) *LogSource {
+	s.logReader.Location = loc
+	return s
+}
+
+// WithBootRegex sets the regex matching the first line of each boot so that the log can be scoped to a boot
+func (s *LogSource) WithBootRegex(bootRegex *regexp.Regexp) *LogSource {
+	s.logReader.BootRegex = bootRegex
+	return s
+}
+
+// WithCurrentBootOnly restricts the log files to those modified since the current boot
+func (s *LogSource) WithCurrentBootOnly(currentBootOnly bool) *LogSource {
+	s.logReader.CurrentBootOnly = currentBootOnly
+	return s
+}
+
+// ClearCache will clear the log reader cache
+func (s *LogSource) ClearCache() {
+	s.logReader.ClearCache()
+}
+
+// String is a human readable string of the source, usually the log file path
+func (s *LogSource) String() string {
+	return s.logReader.Path
+}
+
+// Name is the log source name
+func (s *LogSource) Name() string {
+	return s.name
+}
+
+// Excerpt returns the matched line along with the surrounding lines from the log file
+func (s *LogSource) Excerpt(provenance *Provenance, contextLines int) (string, error) {
+	return s.logReader.Excerpt(provenance, contextLines)
+}
+
+// Explain describes the resolved log files, the nearest matches, and timestamp parsing failures for an event that could not be measured
+func (s *LogSource) Explain(_ *Event, err error) *Explanation {
+	return s.logReader.Explain(err)
+}
+
+// Boots returns the boots found in the log file
+func (s *LogSource) Boots() ([]*Boot, error) {
+	return s.logReader.Boots()
+}
+
+// ScopeToBoot restricts matches to lines logged during the boot
+func (s *LogSource) ScopeToBoot(boot *Boot) {
+	s.logReader.ScopeToBoot(boot)
+}
+
+// FindByRegex is a helper func that returns a FindFunc to search for a regex in a log source that can be used in an Event
+func (s *LogSource) FindByRegex(re *regexp.Regexp) FindFunc {
+	return func(ctx context.Context, _ Source, _ []byte) ([]string, error) {
+		lines, offsets, err := s.logReader.findMatches(re)
+		// the offsets are passed to Find so that repeated lines are located at their match rather than their first occurrence
+		if matchOffsets, ok := ctx.Value(matchOffsetsKey{}).(*[]int); ok {
+			*matchOffsets = offsets
+		}
+		return lines, err
+	}
+}
+
+// Find will use the Event's FindFunc and CommentFunc to search the log source and return the results based on the Event's matcher
+func (s *LogSource) Find(ctx context.Context, event *Event) ([]FindResult, error) {
+	logBytes, err := s.logReader.Read()
+	if err != nil {
+		return nil, err
+	}
+	var matchOffsets []int
+	matchedLines, err := event.FindFn(context.WithValue(ctx, matchOffsetsKey{}, &matchOffsets), s, logBytes)
+	if err != nil {
+		return nil, err
+	}
+	var results []FindResult
+	for i, line := range matchedLines {
+		ts, err := s.logReader.ParseTimestamp(line)
+		comment := ""
+		if event.CommentFn != nil {
+			comment = event.CommentFn(line)
+		}
+		// FindFuncs which do not search with FindByRegex (i.e. custom FindFuncs) are located by the first occurrence of the line
+		matchOffset := -1
+		if len(matchOffsets) == len(matchedLines) {
+			matchOffset = matchOffsets[i]
+		}
+		file, offset, lineNumber := s.logReader.locateMatch(line, matchOffset)
+		results = append(results, FindResult{
+			Line:       line,
+			Timestamp:  ts,
+			Err:        err,
+			Comment:    comment,
+			File:       file,
+			Offset:     offset,
+			LineNumber: lineNumber,
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Timestamp.UnixMicro() < results[j].Timestamp.UnixMicro()
+	})
+	return SelectMatches(results, event.MatchSelector), nil
+}
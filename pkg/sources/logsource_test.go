@@ -0,0 +1,93 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+const repeatedLinesLog = `2024-01-01T00:00:01Z kubelet starting
+2024-01-01T00:00:02Z Started kubelet.service
+2024-01-01T00:00:03Z kubelet exited
+2024-01-01T00:00:02Z Started kubelet.service
+2024-01-01T00:00:05Z kubelet ready
+`
+
+func TestLogSourceLocatesRepeatedLinesAtTheirMatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubelet.log")
+	if err := os.WriteFile(path, []byte(repeatedLinesLog), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	src := NewLogSource("test", path, regexp.MustCompile(`[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}Z`), time.RFC3339)
+	for _, tc := range []struct {
+		name        string
+		selector    string
+		lineNumbers []int
+		excerpt     string
+	}{
+		{
+			name:        "first",
+			selector:    EventMatchSelectorFirst,
+			lineNumbers: []int{2},
+			excerpt:     "2024-01-01T00:00:01Z kubelet starting\n2024-01-01T00:00:02Z Started kubelet.service\n2024-01-01T00:00:03Z kubelet exited",
+		},
+		{
+			name:        "last",
+			selector:    EventMatchSelectorLast,
+			lineNumbers: []int{4},
+			excerpt:     "2024-01-01T00:00:03Z kubelet exited\n2024-01-01T00:00:02Z Started kubelet.service\n2024-01-01T00:00:05Z kubelet ready",
+		},
+		{
+			name:        "all",
+			selector:    EventMatchSelectorAll,
+			lineNumbers: []int{2, 4},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			event := &Event{
+				Name:          "Kubelet Started",
+				FindFn:        FindByRegex(regexp.MustCompile(`.*Started kubelet.service`)),
+				MatchSelector: tc.selector,
+			}
+			results, err := src.Find(context.Background(), event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != len(tc.lineNumbers) {
+				t.Fatalf("expected %d results, got %d", len(tc.lineNumbers), len(results))
+			}
+			for i, result := range results {
+				if result.File != path || result.LineNumber != tc.lineNumbers[i] {
+					t.Errorf("expected %s:%d, got %s:%d", path, tc.lineNumbers[i], result.File, result.LineNumber)
+				}
+			}
+			if tc.excerpt == "" {
+				return
+			}
+			excerpt, err := src.Excerpt(&Provenance{File: results[0].File, Offset: results[0].Offset, Line: results[0].Line}, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if excerpt != tc.excerpt {
+				t.Errorf("expected excerpt %q, got %q", tc.excerpt, excerpt)
+			}
+		})
+	}
+}
@@ -17,7 +17,7 @@ package messages
 
 import (
 	"regexp"
-	"sort"
+	"time"
 
 	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
 )
@@ -27,73 +27,17 @@ var (
 	DefaultPath     = "/var/log/messages*"
 	TimestampFormat = regexp.MustCompile(`[A-Z][a-z]+[ ]+[0-9][0-9]? [0-9]{2}:[0-9]{2}:[0-9]{2}`)
 	TimestampLayout = "Jan 2 15:04:05 2006"
+	// BootRegex matches the kernel version line logged at the start of every boot and journald boot markers
+	BootRegex = regexp.MustCompile(`(?m)kernel: Linux version|^-- Boot (?P<id>[0-9a-f-]+) --$`)
 )
 
-// Source is the /var/log/messages log source
-type Source struct {
-	logReader *sources.LogReader
-}
+// Source is the /var/log/messages log source, it is an alias of sources.LogSource
+type Source = sources.LogSource
 
 // New instantiates a new instance of messages source
 func New(path string) *Source {
-	return &Source{
-		logReader: &sources.LogReader{
-			Path:            path,
-			Glob:            true,
-			TimestampRegex:  TimestampFormat,
-			TimestampLayout: TimestampLayout,
-		},
-	}
-}
-
-// ClearCache will clear the log reader cache
-func (s Source) ClearCache() {
-	s.logReader.ClearCache()
-}
-
-// String is a human readable string of the source, usually the log file path
-func (s Source) String() string {
-	return s.logReader.Path
-}
-
-// Name is the name of the source
-func (s Source) Name() string {
-	return Name
-}
-
-// FindByRegex is a helper func that returns a FindFunc to search for a regex in a log source that can be used in an Event
-func (s Source) FindByRegex(re *regexp.Regexp) sources.FindFunc {
-	return func(_ sources.Source, _ []byte) ([]string, error) {
-		return s.logReader.Find(re)
-	}
-}
-
-// Find will use the Event's FindFunc and CommentFunc to search the log source and return the results based on the Event's matcher
-func (s Source) Find(event *sources.Event) ([]sources.FindResult, error) {
-	logBytes, err := s.logReader.Read()
-	if err != nil {
-		return nil, err
-	}
-	matchedLines, err := event.FindFn(s, logBytes)
-	if err != nil {
-		return nil, err
-	}
-	var results []sources.FindResult
-	for _, line := range matchedLines {
-		ts, err := s.logReader.ParseTimestamp(line)
-		comment := ""
-		if event.CommentFn != nil {
-			comment = event.CommentFn(line)
-		}
-		results = append(results, sources.FindResult{
-			Line:      line,
-			Timestamp: ts,
-			Err:       err,
-			Comment:   comment,
-		})
-	}
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Timestamp.UnixMicro() < results[j].Timestamp.UnixMicro()
-	})
-	return sources.SelectMatches(results, event.MatchSelector), nil
+	return sources.NewLogSource(Name, path, TimestampFormat, TimestampLayout).
+		WithBootRegex(BootRegex).
+		// the syslog daemon writes timestamps without a zone in the host's time zone
+		WithLocation(time.Local)
 }
@@ -16,7 +16,11 @@ package sources
 
 import (
 	"bufio"
+	"bytes"
 	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -25,18 +29,24 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
 )
 
 var (
 	spaceRE = regexp.MustCompile(`\s+`)
+	// now is the current time which the year of timestamps without a year is inferred from
+	now = time.Now
 )
 
 // Source is an interface representing a source of events which have a time stamp or latency associated with them.
 // Most often source is a log file or an API.
 type Source interface {
 	// Find finds the string in the source using a source specific method (could be regex or HTTP path)
-	// If no time.Time could be found an error is returned
-	Find(event *Event) ([]FindResult, error)
+	// If no time.Time could be found an error is returned. Sources must respect the context's deadline.
+	Find(ctx context.Context, event *Event) ([]FindResult, error)
 	// Name is the source name identifier
 	Name() string
 	// ClearCache clears any cached source data
@@ -45,15 +55,32 @@ type Source interface {
 	String() string
 }
 
+// RegexFinder is implemented by log sources that can search their log for a regex
+type RegexFinder interface {
+	Source
+	// FindByRegex returns a FindFunc that searches the log for the regex
+	FindByRegex(re *regexp.Regexp) FindFunc
+}
+
 // FindResult is all data associated with a find including the raw Line data
 type FindResult struct {
 	Line      string
 	Timestamp time.Time
 	Comment   string
 	Err       error
+	// File, Offset, and LineNumber locate the Line within a log source
+	File       string
+	Offset     int64
+	LineNumber int
+}
+
+// Excerpter is an optional interface implemented by sources that can provide the surrounding context of a matched line
+type Excerpter interface {
+	// Excerpt returns the matched line at its provenance along with up to contextLines lines before and after it
+	Excerpt(provenance *Provenance, contextLines int) (string, error)
 }
 
-type FindFunc func(s Source, log []byte) ([]string, error)
+type FindFunc func(ctx context.Context, s Source, log []byte) ([]string, error)
 type CommentFunc func(matchedLine string) string
 
 // Event defines what is being timed from a specific source
@@ -77,11 +104,41 @@ const (
 
 // Timing is a specific instance of an Event timing
 type Timing struct {
-	Event     *Event        `json:"event"`
-	Timestamp time.Time     `json:"timestamp"`
-	T         time.Duration `json:"seconds"`
-	Comment   string        `json:"comment"`
-	Error     error         `json:"error"`
+	Event      *Event        `json:"event"`
+	Timestamp  time.Time     `json:"timestamp"`
+	T          time.Duration `json:"seconds"`
+	Comment    string        `json:"comment"`
+	Error      error         `json:"-"`
+	Provenance *Provenance   `json:"provenance,omitempty"`
+}
+
+// Provenance describes where a Timing was found
+type Provenance struct {
+	// Source is the name of the source the timing was found in
+	Source string `json:"src"`
+	// File is the resolved log file path for log sources
+	File string `json:"file,omitempty"`
+	// Offset is the byte offset of the Line within the File
+	Offset int64 `json:"offset,omitempty"`
+	// LineNumber is the 1-indexed line number of the Line within the File
+	LineNumber int `json:"lineNumber,omitempty"`
+	// Line is the raw matched line or API response
+	Line string `json:"line"`
+}
+
+// MarshalJSON serializes the Timing Error as a string along with its error category
+func (t Timing) MarshalJSON() ([]byte, error) {
+	type timing Timing
+	out := struct {
+		timing
+		Error         string `json:"error,omitempty"`
+		ErrorCategory string `json:"errorCategory,omitempty"`
+	}{timing: timing(t)}
+	if t.Error != nil {
+		out.Error = t.Error.Error()
+		out.ErrorCategory = ErrorCategory(t.Error)
+	}
+	return json.Marshal(out)
 }
 
 // SelectMaches will filter raw results based on the provided matchSelector
@@ -108,6 +165,28 @@ func CommentMatchedLine() func(matchedLine string) string {
 	}
 }
 
+// CommentSubmatch is a helper func that returns a CommentFunc which uses the first submatch of the regex in the matched line as the comment
+func CommentSubmatch(re *regexp.Regexp) CommentFunc {
+	return func(matchedLine string) string {
+		if match := re.FindStringSubmatch(matchedLine); len(match) > 1 {
+			return match[1]
+		}
+		return ""
+	}
+}
+
+// FindByRegex is a helper func that returns a FindFunc which searches any RegexFinder source for the regex,
+// so that the same Event can be registered to different log sources
+func FindByRegex(re *regexp.Regexp) FindFunc {
+	return func(ctx context.Context, s Source, log []byte) ([]string, error) {
+		finder, ok := s.(RegexFinder)
+		if !ok {
+			return nil, Errorf(ErrorCategorySourceUnavailable, "source %s is not a log source", s.Name())
+		}
+		return finder.FindByRegex(re)(ctx, s, log)
+	}
+}
+
 // LogReader is a base Source helper that can Read file contents, cache, and support Glob file paths
 // Other Sources can be built on-top of the LogSrc
 type LogReader struct {
@@ -115,15 +194,37 @@ type LogReader struct {
 	Glob            bool
 	TimestampRegex  *regexp.Regexp
 	TimestampLayout string
-	file            []byte
+	// AltTimestampLayouts are tried in order when a timestamp does not match the TimestampLayout (i.e. logs that changed format between OS versions)
+	AltTimestampLayouts []string
+	// Location is the time zone of timestamps without a zone (i.e. time.Local for syslog timestamps), it defaults to UTC
+	Location *time.Location
+	// CurrentBootOnly restricts glob matches to files that have been modified since the current boot
+	CurrentBootOnly bool
+	// BootRegex matches the first line of each boot, an optional named group "id" captures the boot ID
+	BootRegex *regexp.Regexp
+	// ReadFile reads and decodes a single log file into lines (i.e. binary journal files), it defaults to reading text, gzip, and zstd files
+	ReadFile func(path string) ([]byte, error)
+	// Before reports whether a glob match was written before another, it defaults to comparing the modification times
+	Before   func(a string, b string) bool
+	file     []byte
+	segments []logSegment
+	boot     *Boot
+}
+
+// logSegment is the position of a file within the LogReader's combined log stream
+type logSegment struct {
+	path   string
+	offset int
 }
 
 // ClearCache cleas the cached log
 func (l *LogReader) ClearCache() {
 	l.file = nil
+	l.segments = nil
 }
 
-// Read will open and read all the bytes of a log file into byte slice and then cache it
+// Read will open and read all the bytes of the log files into byte slice and then cache it
+// When Glob is true, all matching files (including .gz and .zst rotated files) are read as one stream from the oldest to the newest file.
 // Any further calls to Read() will use the cached byte slice.
 // If the file is being updated and you need the updated contents,
 // you'll need to instantiate a new LogSrc and call Read() again
@@ -131,96 +232,360 @@ func (l *LogReader) Read() ([]byte, error) {
 	if l.file != nil {
 		return l.file, nil
 	}
-	resolvedPath := l.Path
-	if l.Glob {
-		matches, err := filepath.Glob(l.Path)
-		if err != nil || len(matches) == 0 {
-			return nil, fmt.Errorf("unable to find log file %s: %w", l.Path, err)
-		}
-		// sort to find the oldest file for initial startup timings if the logs were rotated
-		sort.Slice(matches, func(i, j int) bool {
-			iFile, err := os.Open(matches[i])
-			if err != nil {
-				return matches[i] < matches[j]
-			}
-			defer iFile.Close()
-			jFile, err := os.Open(matches[j])
-			if err != nil {
-				return matches[i] < matches[j]
-			}
-			defer jFile.Close()
-			iStat, err := iFile.Stat()
-			if err != nil {
-				return matches[i] < matches[j]
-			}
-			jStat, err := jFile.Stat()
-			if err != nil {
-				return matches[i] < matches[j]
-			}
-			return iStat.ModTime().Unix() < jStat.ModTime().Unix()
-		})
-		resolvedPath = matches[0]
+	paths, err := l.resolveScoped()
+	if err != nil {
+		return nil, err
+	}
+	var stream []byte
+	var segments []logSegment
+	var errs error
+	for _, path := range paths {
+		fileBytes, err := l.readFile(path)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		segments = append(segments, logSegment{path: path, offset: len(stream)})
+		stream = append(stream, fileBytes...)
+		// ensure lines from different files are not joined
+		if len(fileBytes) != 0 && fileBytes[len(fileBytes)-1] != '\n' {
+			stream = append(stream, '\n')
+		}
+	}
+	// rotated files that cannot be read are skipped as long as at least one file could be read
+	if len(segments) == 0 {
+		return nil, errs
 	}
-	file, err := os.Open(resolvedPath)
+	l.file = stream
+	l.segments = segments
+	return stream, nil
+}
+
+// globMatches returns the file paths matching the LogReader's path sorted from oldest to newest by modification time
+func (l *LogReader) globMatches() ([]string, error) {
+	if !l.Glob {
+		return []string{l.Path}, nil
+	}
+	matches, err := filepath.Glob(l.Path)
 	if err != nil {
-		return nil, fmt.Errorf("unable to open log file %s: %w", resolvedPath, err)
+		return nil, Errorf(ErrorCategorySourceUnavailable, "unable to find log file %s: %w", l.Path, err)
 	}
-	defer file.Close()
-	var reader io.Reader
-	if strings.HasSuffix(resolvedPath, ".gz") {
-		gzReader, err := gzip.NewReader(file)
+	if len(matches) == 0 {
+		return nil, Errorf(ErrorCategorySourceUnavailable, "unable to find log file %s", l.Path)
+	}
+	if l.Before != nil {
+		sort.SliceStable(matches, func(i, j int) bool { return l.Before(matches[i], matches[j]) })
+		return matches, nil
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		iStat, err := os.Stat(matches[i])
 		if err != nil {
-			return nil, fmt.Errorf("unable to create gzip reader for file %s: %w", file.Name(), err)
+			return matches[i] < matches[j]
+		}
+		jStat, err := os.Stat(matches[j])
+		if err != nil {
+			return matches[i] < matches[j]
+		}
+		return iStat.ModTime().Before(jStat.ModTime())
+	})
+	return matches, nil
+}
+
+// resolve returns the glob matches that are read by the LogReader, restricted to files modified since the current boot if CurrentBootOnly is set
+func (l *LogReader) resolve() ([]string, error) {
+	matches, err := l.globMatches()
+	if err != nil || !l.Glob || !l.CurrentBootOnly {
+		return matches, err
+	}
+	bootTime, err := BootTime()
+	if err != nil {
+		return nil, NewError(ErrorCategorySourceUnavailable, err)
+	}
+	matches = lo.Filter(matches, func(match string, _ int) bool {
+		stat, err := os.Stat(match)
+		return err == nil && !stat.ModTime().Before(bootTime)
+	})
+	if len(matches) == 0 {
+		return nil, Errorf(ErrorCategorySourceUnavailable, "unable to find log file %s modified since boot at %s", l.Path, bootTime.Format(time.RFC3339))
+	}
+	return matches, nil
+}
+
+// Files returns the log files read by the LogReader from oldest to newest
+func (l *LogReader) Files() ([]string, error) {
+	return l.resolve()
+}
+
+// resolveScoped returns the resolved files which may contain lines of the scoped boot.
+// Files last modified before the boot started are skipped so that rotated logs of earlier boots are not read.
+func (l *LogReader) resolveScoped() ([]string, error) {
+	matches, err := l.resolve()
+	if err != nil || l.boot == nil || !l.Glob {
+		return matches, err
+	}
+	matches = lo.Filter(matches, func(match string, _ int) bool {
+		stat, err := os.Stat(match)
+		return err != nil || !stat.ModTime().Before(l.boot.Start.Add(-bootTimeTolerance))
+	})
+	if len(matches) == 0 {
+		return nil, Errorf(ErrorCategorySourceUnavailable, "unable to find log file %s modified since boot %d started at %s", l.Path, l.boot.Index, l.boot.Start.Format(time.RFC3339))
+	}
+	return matches, nil
+}
+
+// readFile reads a log file with the LogReader's ReadFile func or as a text, gzip, or zstd file
+func (l *LogReader) readFile(path string) ([]byte, error) {
+	if l.ReadFile != nil {
+		return l.ReadFile(path)
+	}
+	return readLogFile(path)
+}
+
+// eachLine calls fn with every line of a log file without reading the whole file into memory unless the LogReader has a ReadFile func
+func (l *LogReader) eachLine(path string, fn func(line []byte)) error {
+	if l.ReadFile != nil {
+		fileBytes, err := l.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, line := range bytes.Split(fileBytes, []byte{'\n'}) {
+			fn(line)
+		}
+		return nil
+	}
+	reader, err := openLogFile(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	bufReader := bufio.NewReader(reader)
+	for {
+		line, err := bufReader.ReadBytes('\n')
+		if len(line) != 0 {
+			fn(bytes.TrimSuffix(line, []byte{'\n'}))
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return Errorf(ErrorCategorySourceUnavailable, "unable to read file %s: %w", path, err)
 		}
-		defer gzReader.Close()
-		reader = gzReader
-	} else {
-		reader = bufio.NewReader(file)
 	}
+}
 
-	fileBytes, err := io.ReadAll(reader)
+// readLogFile reads all the bytes of a log file and decompresses it if it is gzip or zstd compressed
+func readLogFile(path string) ([]byte, error) {
+	reader, err := openLogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	fileBytes, err := io.ReadAll(bufio.NewReader(reader))
 	if err != nil {
-		return fileBytes, fmt.Errorf("unable to read file %s: %w", file.Name(), err)
+		return fileBytes, Errorf(ErrorCategorySourceUnavailable, "unable to read file %s: %w", path, err)
 	}
-	l.file = fileBytes
 	return fileBytes, nil
 }
 
+// logFile closes the decompressor along with the file
+type logFile struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes the decompressor and the file
+func (f *logFile) Close() error {
+	var errs error
+	for _, closer := range f.closers {
+		errs = multierr.Append(errs, closer.Close())
+	}
+	return errs
+}
+
+// openLogFile opens a log file and decompresses it if it is gzip or zstd compressed
+func openLogFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, Errorf(ErrorCategorySourceUnavailable, "unable to open log file %s: %w", path, err)
+	}
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		gzReader, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, Errorf(ErrorCategorySourceUnavailable, "unable to create gzip reader for file %s: %w", file.Name(), err)
+		}
+		return &logFile{Reader: gzReader, closers: []io.Closer{gzReader, file}}, nil
+	case strings.HasSuffix(path, ".zst"):
+		zstdReader, err := zstd.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, Errorf(ErrorCategorySourceUnavailable, "unable to create zstd reader for file %s: %w", file.Name(), err)
+		}
+		return &logFile{Reader: zstdReader, closers: []io.Closer{zstdReader.IOReadCloser(), file}}, nil
+	}
+	return file, nil
+}
+
 // Find searches for the passed in regexp from the log references in the LogReader
 func (l *LogReader) Find(re *regexp.Regexp) ([]string, error) {
+	lines, _, err := l.findMatches(re)
+	return lines, err
+}
+
+// findMatches searches for the regexp and returns the matched lines along with the byte offset of each match in the log
+func (l *LogReader) findMatches(re *regexp.Regexp) ([]string, []int, error) {
 	// Read the log file
 	messages, err := l.Read()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	// Find all occurrences of the regex in the log file
-	lines := re.FindAll(messages, -1)
-	if len(lines) == 0 {
-		return nil, fmt.Errorf("no matches in %s for regex \"%s\"", l.Path, re.String())
+	matches := re.FindAllIndex(messages, -1)
+	if len(matches) == 0 {
+		return nil, nil, &Error{
+			Category: ErrorCategoryNoMatch,
+			Err:      fmt.Errorf("no matches in %s for regex \"%s\"", l.Path, re.String()),
+			Regex:    re,
+		}
 	}
 	var lineStrs []string
-	for _, line := range lines {
-		lineStrs = append(lineStrs, string(line))
+	var offsets []int
+	for _, match := range matches {
+		line := string(messages[match[0]:match[1]])
+		if l.boot != nil {
+			// lines without a timestamp are kept so that the parsing error is surfaced
+			if ts, err := l.ParseTimestamp(line); err == nil && !l.boot.Contains(ts) {
+				continue
+			}
+		}
+		lineStrs = append(lineStrs, line)
+		offsets = append(offsets, match[0])
 	}
-	return lineStrs, nil
+	if len(lineStrs) == 0 {
+		return nil, nil, &Error{
+			Category: ErrorCategoryNoMatch,
+			Err:      fmt.Errorf("no matches in %s for regex \"%s\" during boot %d starting at %s", l.Path, re.String(), l.boot.Index, l.boot.Start.Format(time.RFC3339)),
+			Regex:    re,
+		}
+	}
+	return lineStrs, offsets, nil
 }
 
 // ParseTimestamp usese the configured timestamp regex to find a timestamp from the passed in log line and return as a time.Time
 func (l *LogReader) ParseTimestamp(line string) (time.Time, error) {
 	rawTS := l.TimestampRegex.FindString(line)
 	if rawTS == "" {
-		return time.Time{}, fmt.Errorf("unable to find timestamp on log line matching regex: \"%s\" \"%s\"", l.TimestampRegex.String(), line)
+		return time.Time{}, &Error{
+			Category: ErrorCategoryTimestampParse,
+			Err:      fmt.Errorf("unable to find timestamp on log line matching regex: \"%s\" \"%s\"", l.TimestampRegex.String(), line),
+			Line:     line,
+		}
 	}
 	rawTS = spaceRE.ReplaceAllString(rawTS, " ")
 
-	suffix := ""
-	// Convert timestamp to a time.Time type
-	if !strings.Contains(rawTS, fmt.Sprint(time.Now().Year())) {
-		suffix = fmt.Sprintf(" %d", time.Now().Year())
+	var err error
+	for _, layout := range append([]string{l.TimestampLayout}, l.AltTimestampLayouts...) {
+		var ts time.Time
+		if ts, err = parseTimestamp(layout, rawTS, l.Location); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, &Error{Category: ErrorCategoryTimestampParse, Err: err, Line: line}
+}
+
+// parseTimestamp parses a raw timestamp with the layout in the location, timestamps without a year are assumed to be within the last year
+func parseTimestamp(layout string, rawTS string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
 	}
-	ts, err := time.Parse(l.TimestampLayout, fmt.Sprintf("%s%s", rawTS, suffix))
+	ts, err := time.ParseInLocation(layout, rawTS, loc)
 	if err != nil {
-		return time.Time{}, err
+		now := now()
+		// syslog style timestamps do not include the year so assume the current year
+		var yearErr error
+		ts, yearErr = time.ParseInLocation(layout, fmt.Sprintf("%s %d", rawTS, now.Year()), loc)
+		if yearErr != nil {
+			return time.Time{}, err
+		}
+		// timestamps from rotated logs that would be in the future were logged in the previous year
+		if ts.After(now.Add(24 * time.Hour)) {
+			ts = ts.AddDate(-1, 0, 0)
+		}
+	}
+	return ts.UTC(), nil
+}
+
+// Locate returns the file, byte offset, and 1-indexed line number within that file of the first occurrence of the line in the log.
+// Offsets within compressed files are relative to the decompressed contents.
+func (l *LogReader) Locate(line string) (string, int64, int) {
+	offset := bytes.Index(l.file, []byte(line))
+	if offset == -1 {
+		return "", 0, 0
+	}
+	return l.locateOffset(offset)
+}
+
+// locateMatch returns the file, byte offset, and 1-indexed line number within that file of the line matched at the offset in the log,
+// or of the first occurrence of the line if the offset is not known
+func (l *LogReader) locateMatch(line string, offset int) (string, int64, int) {
+	if !l.matchesAt(line, offset) {
+		return l.Locate(line)
+	}
+	return l.locateOffset(offset)
+}
+
+// matchesAt returns true if the line is at the offset in the log
+func (l *LogReader) matchesAt(line string, offset int) bool {
+	return offset >= 0 && offset+len(line) <= len(l.file) && string(l.file[offset:offset+len(line)]) == line
+}
+
+// locateOffset returns the file, byte offset, and 1-indexed line number within that file of an offset in the log
+func (l *LogReader) locateOffset(offset int) (string, int64, int) {
+	segment, _, ok := lo.FindLastIndexOf(l.segments, func(s logSegment) bool { return s.offset <= offset })
+	if !ok || offset > len(l.file) {
+		return "", 0, 0
+	}
+	return segment.path, int64(offset - segment.offset), bytes.Count(l.file[segment.offset:offset], []byte{'\n'}) + 1
+}
+
+// Excerpt returns the matched line at its provenance in the log along with up to contextLines lines before and after it.
+// The first occurrence of the line is used if the log no longer has the line at its provenance (i.e. it was rotated since the match).
+func (l *LogReader) Excerpt(provenance *Provenance, contextLines int) (string, error) {
+	log, err := l.Read()
+	if err != nil {
+		return "", err
+	}
+	line := provenance.Line
+	start := -1
+	if segment, ok := lo.Find(l.segments, func(s logSegment) bool { return s.path == provenance.File }); ok {
+		start = segment.offset + int(provenance.Offset)
+	}
+	if !l.matchesAt(line, start) {
+		start = bytes.Index(log, []byte(line))
+	}
+	if start == -1 {
+		return "", fmt.Errorf("unable to find line in %s", l.Path)
+	}
+	end := start + len(line)
+	// expand to the full lines containing the match
+	start = bytes.LastIndexByte(log[:start], '\n') + 1
+	if next := bytes.IndexByte(log[end:], '\n'); next == -1 {
+		end = len(log)
+	} else {
+		end += next
+	}
+	// expand by the number of context lines in each direction
+	for i := 0; i < contextLines && start > 0; i++ {
+		start = bytes.LastIndexByte(log[:start-1], '\n') + 1
+	}
+	for i := 0; i < contextLines && end < len(log); i++ {
+		next := bytes.IndexByte(log[end+1:], '\n')
+		if next == -1 {
+			end = len(log)
+			break
+		}
+		end += next + 1
 	}
-	return ts, nil
+	return strings.TrimRight(string(log[start:end]), "\n"), nil
 }
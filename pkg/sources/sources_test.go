@@ -0,0 +1,172 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rotatedLogDir is the fixture of a log rotated across a year boundary into a gzip and a zstd compressed file
+const rotatedLogDir = "../../test/rotated/var/log"
+
+var (
+	syslogTimestampRegex  = regexp.MustCompile(`[A-Z][a-z]+[ ]+[0-9][0-9]? [0-9]{2}:[0-9]{2}:[0-9]{2}`)
+	syslogTimestampLayout = "Jan 2 15:04:05 2006"
+)
+
+// copyRotatedLogs copies the rotated log fixture to a temporary directory with modification times in the order the files were rotated,
+// which differs from the order of their names
+func copyRotatedLogs(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"messages-20231231.gz", "messages-20240101.zst", "messages"} {
+		contents, err := os.ReadFile(filepath.Join(rotatedLogDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, contents, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		fileTime := modTime.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, fileTime, fileTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// withNow sets the current time used to infer the year of timestamps for the duration of the test
+func withNow(t *testing.T, current time.Time) {
+	t.Helper()
+	previous := now
+	now = func() time.Time { return current }
+	t.Cleanup(func() { now = previous })
+}
+
+func TestLogReaderReadsRotatedFilesFromOldestToNewest(t *testing.T) {
+	withNow(t, time.Date(2024, 1, 1, 0, 0, 10, 0, time.UTC))
+	dir := copyRotatedLogs(t)
+	reader := &LogReader{
+		Path:            filepath.Join(dir, "messages*"),
+		Glob:            true,
+		TimestampRegex:  syslogTimestampRegex,
+		TimestampLayout: syslogTimestampLayout,
+	}
+	files, err := reader.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFiles := []string{"messages-20231231.gz", "messages-20240101.zst", "messages"}
+	for i, file := range files {
+		if filepath.Base(file) != expectedFiles[i] {
+			t.Errorf("expected file %d to be %s, got %s", i, expectedFiles[i], filepath.Base(file))
+		}
+	}
+	log, err := reader.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(log)), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("expected the 6 decompressed lines of the 3 files, got %d", len(lines))
+	}
+	// the timestamps increase across the year boundary between the rotated files
+	var previous time.Time
+	for _, line := range lines {
+		ts, err := reader.ParseTimestamp(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ts.After(previous) {
+			t.Errorf("expected %s to be after %s", ts, previous)
+		}
+		previous = ts
+	}
+	if first, _ := reader.ParseTimestamp(lines[0]); !first.Equal(time.Date(2023, 12, 31, 23, 59, 58, 0, time.UTC)) {
+		t.Errorf("expected the first line to be logged in 2023, got %s", first)
+	}
+	for _, tc := range []struct {
+		line       string
+		file       string
+		lineNumber int
+	}{
+		{line: lines[1], file: "messages-20231231.gz", lineNumber: 2},
+		{line: lines[2], file: "messages-20240101.zst", lineNumber: 1},
+		{line: lines[4], file: "messages", lineNumber: 1},
+	} {
+		file, _, lineNumber := reader.Locate(tc.line)
+		if filepath.Base(file) != tc.file || lineNumber != tc.lineNumber {
+			t.Errorf("expected %q at %s:%d, got %s:%d", tc.line, tc.file, tc.lineNumber, filepath.Base(file), lineNumber)
+		}
+	}
+}
+
+func TestParseTimestampInfersTheYear(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		now      time.Time
+		rawTS    string
+		expected time.Time
+	}{
+		{
+			name:     "same year",
+			now:      time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC),
+			rawTS:    "Jun 15 11:00:00",
+			expected: time.Date(2024, 6, 15, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "previous year across the year boundary",
+			now:      time.Date(2024, 1, 1, 0, 0, 10, 0, time.UTC),
+			rawTS:    "Dec 31 23:59:58",
+			expected: time.Date(2023, 12, 31, 23, 59, 58, 0, time.UTC),
+		},
+		{
+			name:     "later in the year is the previous year",
+			now:      time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC),
+			rawTS:    "Jul  1 00:00:00",
+			expected: time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "clock skew within a day is the current year",
+			now:      time.Date(2024, 12, 31, 23, 0, 0, 0, time.UTC),
+			rawTS:    "Dec 31 23:30:00",
+			expected: time.Date(2024, 12, 31, 23, 30, 0, 0, time.UTC),
+		},
+		{
+			name:     "leap day",
+			now:      time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			rawTS:    "Feb 29 12:00:00",
+			expected: time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withNow(t, tc.now)
+			ts, err := parseTimestamp(syslogTimestampLayout, tc.rawTS, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ts.Equal(tc.expected) {
+				t.Errorf("expected %s, got %s", tc.expected, ts)
+			}
+		})
+	}
+}
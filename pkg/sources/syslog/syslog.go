@@ -0,0 +1,47 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package syslog is a latency timing source for the Debian and Ubuntu /var/log/syslog
+package syslog
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/awslabs/node-latency-for-k8s/pkg/sources"
+)
+
+var (
+	Name        = "Syslog"
+	DefaultPath = "/var/log/syslog*"
+	// TimestampFormat matches the RFC3339 timestamps of rsyslog on Ubuntu 23.10+ and the traditional syslog timestamps of earlier versions
+	TimestampFormat = regexp.MustCompile(`[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(\.[0-9]+)?(Z|[+-][0-9]{2}:[0-9]{2})|[A-Z][a-z]+[ ]+[0-9][0-9]? [0-9]{2}:[0-9]{2}:[0-9]{2}`)
+	TimestampLayout = time.RFC3339Nano
+	// TraditionalTimestampLayout is the layout of timestamps without a year
+	TraditionalTimestampLayout = "Jan 2 15:04:05 2006"
+	// BootRegex matches the kernel version line logged at the start of every boot, which rsyslog prefixes with the kernel's uptime
+	BootRegex = regexp.MustCompile(`kernel: (\[ *[0-9.]+\] )?Linux version`)
+)
+
+// Source is the /var/log/syslog log source, it is an alias of sources.LogSource
+type Source = sources.LogSource
+
+// New instantiates a new instance of syslog source
+func New(path string) *Source {
+	return sources.NewLogSource(Name, path, TimestampFormat, TimestampLayout).
+		WithAltTimestampLayouts(TraditionalTimestampLayout).
+		WithBootRegex(BootRegex).
+		// traditional syslog timestamps do not include a zone and are in the host's time zone
+		WithLocation(time.Local)
+}
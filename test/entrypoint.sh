@@ -5,8 +5,8 @@ SCRIPTPATH="$(
   pwd -P
 )"
 
-## Start IMDS mock
-/sbin/ec2-metadata-mock --imdsv2 &> /var/log/ec2-metadata-mock.log > /dev/null 2>&1 &
+## Start IMDS mock, AEMM_ARGS selects an optional subcommand (i.e. spot or events)
+/sbin/ec2-metadata-mock ${AEMM_ARGS:-} --imdsv2 &> /var/log/ec2-metadata-mock.log > /dev/null 2>&1 &
 sleep 1
 
 ## execute any other params